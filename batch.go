@@ -0,0 +1,136 @@
+package simple_cache
+
+import "fmt"
+
+// InsertMany Insert or update all the pairs taking the internal lock once, instead of once per pair
+// as a loop of InsertOrUpdate does. The stringification and serialization are done before taking
+// the lock.
+//
+// It is best-effort: a pair that fails, because its key stringification or value serialization
+// fails or because the cache is full, is skipped and the others are still inserted. Return the
+// errors of the failed pairs, each one naming its key, or nil if all of them were written. The pairs
+// are written in the iteration order of the map, so their relative lru order is unspecified. If
+// SetValueEquals is in use, the no-op detection takes the lock once per pair
+func (cache *SimpleCache) InsertMany(pairs map[interface{}]interface{}) []error {
+
+	if shadow := cache.getShadow(); shadow != nil {
+		_ = shadow.InsertMany(pairs)
+	}
+
+	var errs []error
+	checkNoop := cache.hasValueEquals()
+	batch := make([]preparedPair, 0, len(pairs))
+	for key, value := range pairs {
+		stringKey, err := cache.toMapKey(key)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("key %v: %w", key, err))
+			continue
+		}
+		if cache.updateImmutable(stringKey, value) {
+			continue
+		}
+		if checkNoop {
+			if _, ok := cache.tryNoopUpdate(stringKey, value); ok {
+				continue
+			}
+		}
+		pair, err := cache.prepareValue(key, stringKey, value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("key %v: %w", key, err))
+			continue
+		}
+		batch = append(batch, pair)
+	}
+
+	if len(batch) == 0 {
+		return errs
+	}
+
+	currTime := cache.now()
+
+	defer cache.unlock()
+	cache.writeLock()
+
+	for _, pair := range batch {
+		_, err := cache.storeEntry(pair, currTime, insertOptions{ttl: cache.ttl})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("key %v: %w", pair.key, err))
+		}
+	}
+
+	return errs
+}
+
+// ReadMany Read all the keys taking the internal lock once, instead of once per key as a loop of
+// Read does. Each key is read as by Read: hits and misses are counted, the found entries are
+// refreshed and moved to the mru in the order of keys, and expired entries with a reloader are
+// reloaded. The decoding is done after releasing the lock.
+//
+// Return the values found, indexed by the keys as passed, so keys must be comparable, and a slice
+// of the length of keys with the error Read would have returned for each key, nil for the found ones
+func (cache *SimpleCache) ReadMany(keys []interface{}) (map[interface{}]interface{}, []error) {
+
+	if shadow := cache.getShadow(); shadow != nil {
+		_, _ = shadow.ReadMany(keys)
+	}
+
+	values := make(map[interface{}]interface{}, len(keys))
+	errs := make([]error, len(keys))
+
+	stored := make([]interface{}, len(keys))
+	raws := make([]bool, len(keys))
+	reloads := make([]func() (interface{}, error), len(keys))
+	stringKeys := make([]string, len(keys))
+	pending := make([]int, 0, len(keys)) // positions to be looked up in the table
+	for i, key := range keys {
+		stringKey, err := cache.toMapKey(key)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		if value, ok := cache.readImmutable(stringKey); ok {
+			values[key] = value
+			continue
+		}
+		stringKeys[i] = stringKey
+		pending = append(pending, i)
+	}
+
+	if len(pending) > 0 {
+		func() {
+			defer cache.unlock()
+			cache.writeLock()
+
+			currTime := cache.now()
+			for _, i := range pending {
+				stored[i], raws[i], reloads[i], errs[i] = cache.readLocked(stringKeys[i], currTime)
+			}
+		}()
+	}
+
+	for _, i := range pending {
+		key := keys[i]
+		switch {
+		case reloads[i] != nil:
+			value, err := cache.reloadEntry(key, stringKeys[i], reloads[i])
+			if err != nil {
+				errs[i] = err
+				continue
+			}
+			values[key] = value
+		case errs[i] != nil: // not found or expired
+		case !cache.toCompress || raws[i]:
+			values[key] = stored[i]
+		default:
+			value, err := cache.decodeValue(stored[i].([]byte))
+			if err != nil {
+				cache.serializationFailed(stringKeys[i], err)
+				errs[i] = err
+				continue
+			}
+			values[key] = value
+		}
+	}
+
+	return values, errs
+}
@@ -0,0 +1,119 @@
+package simple_cache
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestInsertManyReadMany(t *testing.T) {
+
+	ttl := time.Minute
+	cache := New(4, Factor, ttl, func(key interface{}) (string, error) {
+		n, ok := key.(int)
+		if !ok {
+			return "", errors.New("not an int")
+		}
+		return strconv.Itoa(n), nil
+	})
+	clock := newFakeClock(cache)
+
+	errs := cache.InsertMany(map[interface{}]interface{}{0: 0, 1: 1, 2: 2, "bad": 3})
+	assert.Len(t, errs, 1) // best-effort: the other pairs are inserted
+	assert.Contains(t, errs[0].Error(), "bad")
+	assert.Equal(t, 3, cache.NumEntries())
+	assert.Equal(t, 3, cache.MissCount())
+
+	// one pair does not fit, the update and the other insertion do
+	errs = cache.InsertMany(map[interface{}]interface{}{2: 20, 3: 3, 4: 4})
+	assert.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], ErrCacheFull)
+	assert.Equal(t, 4, cache.NumEntries())
+	assert.Equal(t, 1, cache.HitCount())
+
+	clock.Advance(ttl / 2)
+	_, err := cache.Read(0) // refreshed, so it survives the next advance
+	assert.NoError(t, err)
+	clock.Advance(ttl / 2)
+
+	keys := []interface{}{0, 1, 2, 7, "bad"}
+	values, errs := cache.ReadMany(keys)
+	assert.Len(t, errs, len(keys))
+	assert.Equal(t, map[interface{}]interface{}{0: 0}, values)
+	assert.NoError(t, errs[0])
+	assert.ErrorIs(t, errs[1], ErrKeyExpired)
+	assert.ErrorIs(t, errs[2], ErrKeyExpired)
+	assert.ErrorIs(t, errs[3], ErrKeyNotFound)
+	assert.Error(t, errs[4])
+
+	assert.Nil(t, cache.InsertMany(map[interface{}]interface{}{1: 10, 2: 20}))
+	values, errs = cache.ReadMany([]interface{}{2, 1})
+	assert.Equal(t, []error{nil, nil}, errs)
+	assert.Equal(t, map[interface{}]interface{}{1: 10, 2: 20}, values)
+	key, _, err := cache.GetMRU()
+	assert.NoError(t, err)
+	assert.Equal(t, "1", key) // promoted in the order of the keys
+}
+
+func TestReadManyCompressed(t *testing.T) {
+
+	cache := newValueTypeCache(Capacity, time.Hour)
+
+	pairs := make(map[interface{}]interface{})
+	for i := 0; i < 10; i++ {
+		pairs[i] = &ValueType{Num: i, Text: strconv.Itoa(i)}
+	}
+	pairs[10] = "not a *ValueType"
+	errs := cache.InsertMany(pairs)
+	assert.Len(t, errs, 1)
+
+	values, errs := cache.ReadMany([]interface{}{3, 10})
+	assert.NoError(t, errs[0])
+	assert.Equal(t, &ValueType{Num: 3, Text: "3"}, values[3])
+	assert.ErrorIs(t, errs[1], ErrKeyNotFound)
+}
+
+func BenchmarkBatchLoad(b *testing.B) {
+
+	const pageSize = 200
+	toMapKey := func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	}
+
+	page := make(map[interface{}]interface{}, pageSize)
+	keys := make([]interface{}, 0, pageSize)
+	for i := 0; i < pageSize; i++ {
+		page[i] = i
+		keys = append(keys, i)
+	}
+
+	b.Run("loop", func(b *testing.B) {
+		cache := New(pageSize, Factor, time.Hour, toMapKey)
+		for n := 0; n < b.N; n++ {
+			for key, value := range page {
+				if _, err := cache.InsertOrUpdate(key, value); err != nil {
+					b.Fatal(err)
+				}
+			}
+			for _, key := range keys {
+				if _, err := cache.Read(key); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+
+	b.Run("batch", func(b *testing.B) {
+		cache := New(pageSize, Factor, time.Hour, toMapKey)
+		for n := 0; n < b.N; n++ {
+			if errs := cache.InsertMany(page); errs != nil {
+				b.Fatal(errs)
+			}
+			if _, errs := cache.ReadMany(keys); len(errs) != pageSize {
+				b.Fatal(errs)
+			}
+		}
+	})
+}
@@ -0,0 +1,240 @@
+package simple_cache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Binary snapshot layout (all integers big endian):
+//
+//	magic    [4]byte "SCBD"
+//	version  byte
+//	capacity uint64
+//	ttl      int64 nanoseconds
+//	count    uint64 number of entries
+//
+// followed by count entries, from lru to mru, each one prefixed by the uint32 length of its body:
+//
+//	expiration int64 unix nanoseconds
+//	timestamp  int64 unix nanoseconds
+//	ttl        int64 nanoseconds
+//	order      int64
+//	keyLen     uint32
+//	key        [keyLen]byte
+//	value      the rest of the body, as stored by the cache
+var binaryDumpMagic = [4]byte{'S', 'C', 'B', 'D'}
+
+const binaryDumpVersion = 1
+
+// ErrBadSnapshot is returned by ImportBinary when the input is not a snapshot of the current version
+var ErrBadSnapshot = errors.New("bad binary snapshot")
+
+const binaryEntryFixedSize = 4*8 + 4 // expiration, timestamp, ttl, order and keyLen
+
+type binaryEntry struct {
+	key        string
+	value      interface{} // []byte as stored by the cache, unless raw
+	raw        bool        // never set by ExportBinary
+	expiration int64
+	timestamp  int64
+	ttl        int64
+	order      int64
+}
+
+// ExportBinary Write the live entries to w in a compact versioned binary format, from lru to mru,
+// with their stored bytes (compressed, and encrypted if so configured), remaining lifetime and ttl.
+// Only available in compression mode. Pinned keys and spilled entries are not exported, and
+// neither are the values stored raw because their serialization failed.
+//
+// Uses internal lock. The entries are collected under the lock and written without it
+func (cache *SimpleCache) ExportBinary(w io.Writer) error {
+
+	if !cache.toCompress {
+		return errors.New("ExportBinary requires a cache created with NewWithCompression")
+	}
+
+	var entries []binaryEntry
+	var capacity int64 // captured with the entries, since Resize can change it
+	func() {
+		defer cache.lock.Unlock()
+		cache.writeLock()
+
+		capacity = cache.capacity
+		currTime := cache.now()
+		for entry := cache.head.prev; entry != &cache.head; entry = entry.prev {
+			if entry.state != BUSY || entry.raw || entry.hasExpired(currTime) {
+				continue
+			}
+			entries = append(entries, binaryEntry{
+				key:        entry.key,
+				value:      entry.value.([]byte), // stored values are never modified in place
+				expiration: entry.expirationTime().UnixNano(),
+				timestamp:  entry.timestamp.UnixNano(),
+				ttl:        int64(entry.ttl),
+				order:      entry.order,
+			})
+		}
+	}()
+
+	out := bufio.NewWriter(w)
+
+	var header [4 + 1 + 3*8]byte
+	copy(header[:4], binaryDumpMagic[:])
+	header[4] = binaryDumpVersion
+	binary.BigEndian.PutUint64(header[5:], uint64(capacity))
+	binary.BigEndian.PutUint64(header[13:], uint64(cache.ttl))
+	binary.BigEndian.PutUint64(header[21:], uint64(len(entries)))
+	if _, err := out.Write(header[:]); err != nil {
+		return err
+	}
+
+	var fixed [4 + binaryEntryFixedSize]byte
+	for _, entry := range entries {
+		value := entry.value.([]byte)
+		binary.BigEndian.PutUint32(fixed[0:], uint32(binaryEntryFixedSize+len(entry.key)+len(value)))
+		binary.BigEndian.PutUint64(fixed[4:], uint64(entry.expiration))
+		binary.BigEndian.PutUint64(fixed[12:], uint64(entry.timestamp))
+		binary.BigEndian.PutUint64(fixed[20:], uint64(entry.ttl))
+		binary.BigEndian.PutUint64(fixed[28:], uint64(entry.order))
+		binary.BigEndian.PutUint32(fixed[36:], uint32(len(entry.key)))
+		if _, err := out.Write(fixed[:]); err != nil {
+			return err
+		}
+		if _, err := out.WriteString(entry.key); err != nil {
+			return err
+		}
+		if _, err := out.Write(value); err != nil {
+			return err
+		}
+	}
+
+	return out.Flush()
+}
+
+// ImportBinary Load a snapshot written by ExportBinary. The whole snapshot is read and validated
+// before touching the cache, so a snapshot with a wrong magic number or version, or a truncated one,
+// is rejected with an error wrapping ErrBadSnapshot and the cache is left as it was.
+//
+// The entries are then inserted from lru to mru, replacing the entries with the same key, and keep
+// the expiration time they had when exported; the ones expired meanwhile are skipped. The capacity
+// and ttl of the snapshot are informative: the ones of cache apply. The values are stored as they
+// are, so the cache must be configured with the same serialization and encryption as the exporter.
+// If the cache fills up with live entries, the import stops with ErrCacheFull. Counters are not
+// changed. Only available in compression mode.
+//
+// Uses internal lock
+func (cache *SimpleCache) ImportBinary(r io.Reader) error {
+
+	if !cache.toCompress {
+		return errors.New("ImportBinary requires a cache created with NewWithCompression")
+	}
+
+	entries, err := readBinaryDump(bufio.NewReader(r))
+	if err != nil {
+		return err
+	}
+
+	defer cache.unlock()
+	cache.writeLock()
+
+	return cache.restoreEntries(entries)
+}
+
+// Insert the entries read from a snapshot from lru to mru, skipping the expired ones and replacing the
+// entries with the same key. The values are stored as they are; mutex must be taken
+func (cache *SimpleCache) restoreEntries(entries []binaryEntry) error {
+
+	currTime := cache.now()
+	for _, restored := range entries {
+		if restored.expiration < currTime.UnixNano() {
+			continue
+		}
+		cost, err := cache.storedCost(restored.key, restored.value)
+		if err != nil {
+			return err
+		}
+		if entry := cache.table[restored.key]; entry != nil {
+			cache.removeEntry(entry)
+		}
+		if cache.spill != nil {
+			cache.spill.remove(restored.key)
+		}
+		if err = cache.reserveCost(cost, nil, currTime); err != nil {
+			return err
+		}
+		entry, err := cache.allocateEntry(restored.key)
+		if err == ErrCacheFull && cache.removeExpired(currTime, nil) > 0 {
+			entry, err = cache.allocateEntry(restored.key)
+		}
+		if err != nil {
+			return err
+		}
+		entry.value = restored.value
+		entry.raw = restored.raw
+		cache.setCost(entry, cost)
+		entry.reload = nil
+		entry.version++
+		entry.timestamp = time.Unix(0, restored.timestamp)
+		entry.ttl = time.Duration(restored.ttl)
+		entry.setExpirationTime(time.Unix(0, restored.expiration))
+		entry.order = restored.order
+		if cache.orderKey != nil {
+			cache.insertIntoOrderIndex(entry)
+		}
+	}
+
+	return nil
+}
+
+// Read and validate a whole snapshot
+func readBinaryDump(r io.Reader) ([]binaryEntry, error) {
+
+	var header [4 + 1 + 3*8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, fmt.Errorf("%w: cannot read header: %v", ErrBadSnapshot, err)
+	}
+	if !bytes.Equal(header[:4], binaryDumpMagic[:]) {
+		return nil, fmt.Errorf("%w: wrong magic number %q", ErrBadSnapshot, header[:4])
+	}
+	if header[4] != binaryDumpVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrBadSnapshot, header[4])
+	}
+	count := binary.BigEndian.Uint64(header[21:])
+
+	var entries []binaryEntry
+	var size [4]byte
+	for i := uint64(0); i < count; i++ {
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return nil, fmt.Errorf("%w: cannot read entry %d: %v", ErrBadSnapshot, i, err)
+		}
+		n := binary.BigEndian.Uint32(size[:])
+		if n < binaryEntryFixedSize {
+			return nil, fmt.Errorf("%w: entry %d is too short", ErrBadSnapshot, i)
+		}
+		// read progressively, so that a corrupted length does not allocate a huge buffer upfront
+		body, err := io.ReadAll(io.LimitReader(r, int64(n)))
+		if err != nil || uint32(len(body)) != n {
+			return nil, fmt.Errorf("%w: entry %d is truncated", ErrBadSnapshot, i)
+		}
+		keyLen := binary.BigEndian.Uint32(body[32:])
+		if keyLen > n-binaryEntryFixedSize {
+			return nil, fmt.Errorf("%w: entry %d has a wrong key length", ErrBadSnapshot, i)
+		}
+		key := body[binaryEntryFixedSize : binaryEntryFixedSize+keyLen]
+		entries = append(entries, binaryEntry{
+			key:        string(key),
+			value:      body[binaryEntryFixedSize+keyLen:],
+			expiration: int64(binary.BigEndian.Uint64(body[0:])),
+			timestamp:  int64(binary.BigEndian.Uint64(body[8:])),
+			ttl:        int64(binary.BigEndian.Uint64(body[16:])),
+			order:      int64(binary.BigEndian.Uint64(body[24:])),
+		})
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,80 @@
+package simple_cache
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestExportImportBinary(t *testing.T) {
+
+	cache := newValueTypeCache(Capacity, time.Hour)
+	clock := newFakeClock(cache)
+	for i := 0; i < 10; i++ {
+		_, err := cache.InsertOrUpdate(i, &ValueType{Num: i, Text: strconv.Itoa(i)})
+		assert.NoError(t, err)
+	}
+	_, err := cache.Read(3)
+	assert.NoError(t, err)
+	_, err = cache.InsertOrUpdateWithTTL(10, &ValueType{Num: 10}, 50*time.Millisecond)
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.NoError(t, cache.ExportBinary(&buf))
+	snapshot := buf.Bytes()
+
+	clock.Advance(50 * time.Millisecond) // 10 expires before being imported
+
+	restored := newValueTypeCache(Capacity, time.Hour)
+	restored.SetClock(clock.Now)
+	assert.NoError(t, restored.ImportBinary(bytes.NewReader(snapshot)))
+	assert.Equal(t, 10, restored.NumEntries())
+	assert.Equal(t, cacheKeys(cache)[1:], cacheKeys(restored)) // without 10, the mru
+	assert.Equal(t, cache.table["3"].expirationTime(), restored.table["3"].expirationTime())
+	for i := 0; i < 10; i++ {
+		value, err := restored.Read(i)
+		assert.NoError(t, err)
+		assert.Equal(t, strconv.Itoa(i), value.(*ValueType).Text)
+	}
+
+	// importing over existing keys replaces their values
+	_, err = restored.InsertOrUpdate(5, &ValueType{Num: 50})
+	assert.NoError(t, err)
+	assert.NoError(t, restored.ImportBinary(bytes.NewReader(snapshot)))
+	assert.Equal(t, 10, restored.NumEntries())
+	value, err := restored.Read(5)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, value.(*ValueType).Num)
+}
+
+func TestImportBinaryRejectsBadSnapshots(t *testing.T) {
+
+	cache := newValueTypeCache(Capacity, time.Hour)
+	for i := 0; i < 3; i++ {
+		_, err := cache.InsertOrUpdate(i, &ValueType{Num: i})
+		assert.NoError(t, err)
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, cache.ExportBinary(&buf))
+	snapshot := buf.Bytes()
+
+	badMagic := append([]byte("JSON"), snapshot[4:]...)
+	badVersion := append([]byte(nil), snapshot...)
+	badVersion[4] = binaryDumpVersion + 1
+	truncated := snapshot[:len(snapshot)-1]
+
+	restored := newValueTypeCache(Capacity, time.Hour)
+	for _, input := range [][]byte{badMagic, badVersion, truncated, nil} {
+		err := restored.ImportBinary(bytes.NewReader(input))
+		assert.ErrorIs(t, err, ErrBadSnapshot)
+		assert.Equal(t, 0, restored.NumEntries())
+	}
+
+	plain := New(Capacity, Factor, time.Hour, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	assert.Error(t, plain.ExportBinary(&buf))
+	assert.Error(t, plain.ImportBinary(bytes.NewReader(snapshot)))
+}
@@ -0,0 +1,120 @@
+package simple_cache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Load started by the first reader that missed a key
+type coalescedLoad struct {
+	started time.Time
+	done    chan struct{}
+	value   interface{}
+	err     error
+}
+
+type missCoalescer struct {
+	lock    sync.Mutex
+	window  time.Duration
+	timeout time.Duration
+	loads   map[string]*coalescedLoad
+}
+
+// CoalesceMisses Configure the miss coalescing of ReadCoalesced: the readers that miss a key
+// within window since the first miss do not load it; they wait up to timeout for the result of
+// the load started by the first one. A window of 0 disables the coalescing (default)
+func (cache *SimpleCache) CoalesceMisses(window, timeout time.Duration) {
+
+	cache.misses.lock.Lock()
+	defer cache.misses.lock.Unlock()
+
+	cache.misses.window = window
+	cache.misses.timeout = timeout
+}
+
+// ReadCoalesced Read key and, on a miss, load it with loader and insert the result.
+//
+// Unlike a plain single flight, coalescing is bounded in time (see CoalesceMisses):
+// the result of a load, including its error, is shared with every reader that misses the key
+// within the window, and those readers give up with ErrCoalesceTimeout if the load takes longer
+// than the timeout. A miss after the window starts a new load. A panic in loader is recovered and
+// returned as an error, to the leader and to the readers sharing its load.
+func (cache *SimpleCache) ReadCoalesced(key interface{}, loader func() (interface{}, error)) (interface{}, error) {
+
+	value, err := cache.Read(key)
+	if err == nil {
+		return value, nil
+	}
+	if !errors.Is(err, ErrKeyExpired) && !errors.Is(err, ErrKeyNotFound) {
+		return nil, err
+	}
+
+	stringKey, err := cache.toMapKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	load, leader, timeout := cache.misses.join(stringKey, cache.now())
+	if !leader {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case <-load.done:
+			return load.value, load.err
+		case <-timer.C:
+			return nil, fmt.Errorf("stringficated key %s: %w", stringKey, ErrCoalesceTimeout)
+		}
+	}
+
+	func() {
+		// a panic in loader must not be seen by the waiters as a successful load of nil
+		defer func() {
+			if r := recover(); r != nil {
+				load.err = fmt.Errorf("load for stringficated key %s panicked: %v", stringKey, r)
+			}
+			if load.err != nil {
+				load.value = nil
+			}
+			close(load.done)
+		}()
+		load.value, load.err = loader()
+		if load.err == nil {
+			_, load.err = cache.insertOrUpdate(key, load.value, insertOptions{ttl: cache.ttl, refresh: true})
+		}
+	}()
+
+	return load.value, load.err
+}
+
+// Return the load of stringKey to wait for, or register a new one and return leader = true
+func (coalescer *missCoalescer) join(stringKey string, currTime time.Time) (*coalescedLoad, bool, time.Duration) {
+
+	coalescer.lock.Lock()
+	defer coalescer.lock.Unlock()
+
+	load := coalescer.loads[stringKey]
+	if load != nil && currTime.Sub(load.started) <= coalescer.window {
+		return load, false, coalescer.timeout
+	}
+
+	load = &coalescedLoad{started: currTime, done: make(chan struct{})}
+	if coalescer.window <= 0 {
+		return load, true, 0 // not shared
+	}
+
+	if coalescer.loads == nil {
+		coalescer.loads = make(map[string]*coalescedLoad)
+	}
+	coalescer.loads[stringKey] = load
+	time.AfterFunc(coalescer.window, func() {
+		coalescer.lock.Lock()
+		defer coalescer.lock.Unlock()
+		if coalescer.loads[stringKey] == load {
+			delete(coalescer.loads, stringKey)
+		}
+	})
+
+	return load, true, 0
+}
@@ -0,0 +1,119 @@
+package simple_cache
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadCoalesced(t *testing.T) {
+
+	cache := New(Capacity, Factor, time.Hour, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	cache.CoalesceMisses(time.Second, time.Second)
+
+	var loads int32
+	release := make(chan struct{})
+	loader := func() (interface{}, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return 42, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := cache.ReadCoalesced(1, loader)
+			assert.NoError(t, err)
+			assert.Equal(t, 42, value)
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+
+	value, err := cache.Read(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 42, value)
+
+	// Errors are shared within the window too
+	failures := 0
+	failing := func() (interface{}, error) {
+		failures++
+		return nil, errors.New("backend down")
+	}
+	_, err = cache.ReadCoalesced(2, failing)
+	assert.Error(t, err)
+	_, err = cache.ReadCoalesced(2, failing)
+	assert.EqualError(t, err, "backend down")
+	assert.Equal(t, 1, failures)
+}
+
+func TestReadCoalescedTimeout(t *testing.T) {
+
+	cache := New(Capacity, Factor, time.Hour, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	cache.CoalesceMisses(time.Second, 10*time.Millisecond)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go func() {
+		_, _ = cache.ReadCoalesced(1, func() (interface{}, error) {
+			close(started)
+			<-release
+			return 1, nil
+		})
+	}()
+	<-started
+
+	_, err := cache.ReadCoalesced(1, func() (interface{}, error) {
+		t.Fatal("the load should be coalesced")
+		return nil, nil
+	})
+	assert.ErrorIs(t, err, ErrCoalesceTimeout)
+	close(release)
+}
+
+func TestReadCoalescedPanic(t *testing.T) {
+
+	cache := New(Capacity, Factor, time.Hour, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	cache.CoalesceMisses(time.Second, time.Second)
+
+	started := make(chan struct{})
+	waiterDone := make(chan error)
+	go func() {
+		<-started
+		_, err := cache.ReadCoalesced(1, func() (interface{}, error) {
+			t.Error("the load should be coalesced")
+			return nil, nil
+		})
+		waiterDone <- err
+	}()
+
+	_, err := cache.ReadCoalesced(1, func() (interface{}, error) {
+		close(started)
+		time.Sleep(10 * time.Millisecond) // let the other reader join the load
+		panic("upstream exploded")
+	})
+	assert.ErrorContains(t, err, "upstream exploded")
+	assert.ErrorContains(t, <-waiterDone, "upstream exploded")
+
+	// a later miss within the window shares the error too
+	_, err = cache.ReadCoalesced(1, func() (interface{}, error) {
+		t.Error("the load should be coalesced")
+		return nil, nil
+	})
+	assert.ErrorContains(t, err, "upstream exploded")
+	assert.Equal(t, 0, cache.NumEntries())
+}
@@ -0,0 +1,76 @@
+package simple_cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"time"
+)
+
+// Codec compresses the serialized values before they are stored, and decompresses them on reading
+type Codec interface {
+	Compress([]byte) ([]byte, error)
+	Decompress([]byte) ([]byte, error)
+}
+
+// Lz4Codec Compress with LZ4 frames. It is the codec of NewWithCompression: fast, with a moderate ratio
+type Lz4Codec struct{}
+
+func (Lz4Codec) Compress(in []byte) ([]byte, error) {
+	return lz4Compress(in)
+}
+
+func (Lz4Codec) Decompress(in []byte) ([]byte, error) {
+	return lz4Decompress(in)
+}
+
+// GzipCodec Compress with gzip. Slower than LZ4, but with a better ratio for text-heavy values
+type GzipCodec struct{}
+
+func (GzipCodec) Compress(in []byte) ([]byte, error) {
+	w := &bytes.Buffer{}
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(in); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
+
+func (GzipCodec) Decompress(in []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(in))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// NoopCodec Store the serialized values as they are
+type NoopCodec struct{}
+
+func (NoopCodec) Compress(in []byte) ([]byte, error) {
+	return in, nil
+}
+
+func (NoopCodec) Decompress(in []byte) ([]byte, error) {
+	return in, nil
+}
+
+// NewWithCodec Like NewWithCompression, but the serialized values are compressed with codec instead of LZ4
+func NewWithCodec(capacity int, capFactor float64, ttl time.Duration,
+	toMapKey func(key interface{}) (string, error),
+	valueToBytes func(value interface{}) ([]byte, error),
+	bytesToValue func([]byte) (interface{}, error),
+	codec Codec,
+) *SimpleCache {
+
+	cache := NewWithCompression(capacity, capFactor, ttl, toMapKey, valueToBytes, bytesToValue)
+	if cache != nil {
+		cache.codec = codec
+	}
+
+	return cache
+}
@@ -0,0 +1,63 @@
+package simple_cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newCodecCache(codec Codec) *SimpleCache {
+	return NewWithCodec(Capacity, Factor, time.Hour,
+		func(key interface{}) (string, error) {
+			return strconv.Itoa(key.(int)), nil
+		}, func(value interface{}) ([]byte, error) {
+			return json.Marshal(value.(*ValueType))
+		},
+		func(buf []byte) (interface{}, error) {
+			value := &ValueType{}
+			err := json.Unmarshal(buf, value)
+			if err != nil {
+				return nil, err
+			}
+			return value, nil
+		}, codec)
+}
+
+func TestCodecs(t *testing.T) {
+
+	text := strings.Repeat("a highly compressible text ", 100)
+	raw, err := json.Marshal(&ValueType{Num: 1, Text: text})
+	assert.NoError(t, err)
+
+	for _, codec := range []Codec{Lz4Codec{}, GzipCodec{}, NoopCodec{}} {
+		t.Run(fmt.Sprintf("%T", codec), func(t *testing.T) {
+			cache := newCodecCache(codec)
+
+			_, err := cache.InsertOrUpdate(1, &ValueType{Num: 1, Text: text})
+			assert.NoError(t, err)
+
+			value, err := cache.Read(1)
+			assert.NoError(t, err)
+			assert.Equal(t, text, value.(*ValueType).Text)
+
+			stored, err := cache.ReadCompressed(1)
+			assert.NoError(t, err)
+			if _, noop := codec.(NoopCodec); noop {
+				assert.Equal(t, raw, stored)
+			} else {
+				assert.Less(t, len(stored), len(raw))
+			}
+
+			decompressed, err := codec.Decompress(stored)
+			assert.NoError(t, err)
+			assert.Equal(t, raw, decompressed)
+		})
+	}
+
+	_, err = GzipCodec{}.Decompress([]byte("not gzip"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,123 @@
+package simple_cache
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// ErrCostTooHigh is wrapped by the errors returned when a value costs more than the maximum cost of the cache
+var ErrCostTooHigh = errors.New("cost exceeds the maximum cost")
+
+// Pre-allocation of the table of a cache bounded by cost, whose number of entries is not known
+const costInitialMapSize = 1024
+
+// NewWithCost Create a cache bounded by the total cost of its entries, for instance their size in
+// bytes, instead of by their number. costOf tells the cost of each value when it is written, and an
+// update adjusts the total by the difference between the new and the old cost. A value costing more
+// than maxCost, or a negative cost, is rejected with an error.
+//
+// Room for a new cost is made as for a new entry in a cache bounded by count: the expired entries are
+// reclaimed, and the live ones are only evicted if they can be spilled to disk; otherwise the
+// insertion fails with ErrCacheFull. The number of entries is bounded by maxCost too, so values of
+// cost 0 cannot grow the cache without limit
+func NewWithCost(maxCost int64, capFactor float64, ttl time.Duration,
+	toMapKey func(key interface{}) (string, error),
+	costOf func(value interface{}) int64,
+) *SimpleCache {
+
+	if maxCost <= 0 {
+		panic(fmt.Sprintf("invalid maxCost %d. It should be positive", maxCost))
+	}
+
+	capacity := maxCost
+	if capacity > math.MaxInt32 {
+		capacity = math.MaxInt32
+	}
+	cache := newCache(int(capacity), capFactor, ttl, toMapKey, costInitialMapSize)
+	cache.maxCost = maxCost
+	cache.costOf = costOf
+
+	return cache
+}
+
+// TotalCost Return the sum of the costs of the entries in the cache. It is 0 if the cache was not
+// created with NewWithCost
+func (cache *SimpleCache) TotalCost() int64 {
+	return atomic.LoadInt64(&cache.totalCost)
+}
+
+// Return the cost of value as it is stored, or error if it cannot be stored. Does not need the lock
+func (cache *SimpleCache) storedCost(stringKey string, storedValue interface{}) (int64, error) {
+
+	if cache.costOf == nil {
+		return 0, nil
+	}
+
+	cost := cache.costOf(storedValue)
+	if cost < 0 {
+		return 0, fmt.Errorf("stringficated key %s has a negative cost %d", stringKey, cost)
+	}
+	if cost > cache.maxCost {
+		return 0, fmt.Errorf("stringficated key %s costs %d: %w %d", stringKey, cost, ErrCostTooHigh, cache.maxCost)
+	}
+
+	return cost, nil
+}
+
+// Tell whether an entry of the given cost can be added without evicting anything; mutex must be taken
+func (cache *SimpleCache) hasRoom(cost int64) bool {
+	return cache.numEntries < cache.capacity && cache.hasCostRoom(cost)
+}
+
+// Tell whether cost more fits in maxCost without evicting anything; mutex must be taken
+func (cache *SimpleCache) hasCostRoom(cost int64) bool {
+	return cache.maxCost == 0 || cache.totalCost+cost <= cache.maxCost
+}
+
+// Account cost as the cost of entry; mutex must be taken
+func (cache *SimpleCache) setCost(entry *SimpleCacheEntry, cost int64) {
+
+	atomic.AddInt64(&cache.totalCost, cost-entry.cost)
+	entry.cost = cost
+	cache.checkFillThresholds()
+}
+
+// Evict entries until cost more fits in maxCost, never keep; mutex must be taken. The expired entries
+// are reclaimed first, then the live ones from the lru end as long as they can be spilled to disk.
+// Return ErrCacheFull if there is not enough room yet
+func (cache *SimpleCache) reserveCost(cost int64, keep *SimpleCacheEntry, currTime time.Time) error {
+
+	if cache.maxCost == 0 || cache.totalCost+cost <= cache.maxCost {
+		return nil
+	}
+
+	for entry := cache.head.prev; entry != &cache.head && cache.totalCost+cost > cache.maxCost; {
+		prev := entry.prev
+		if entry != keep && entry.state == BUSY && entry.hasExpired(currTime) {
+			cache.evicted(entry, EvictExpired)
+			cache.removeEntry(entry)
+		}
+		entry = prev
+	}
+
+	for entry := cache.head.prev; entry != &cache.head && cache.totalCost+cost > cache.maxCost; {
+		prev := entry.prev
+		if entry != keep && entry.state == BUSY {
+			if cache.spill == nil || cache.spill.store(entry, currTime) != nil {
+				return ErrCacheFull
+			}
+			atomic.AddInt64(&cache.evictionCount, 1)
+			cache.removeEntry(entry)
+		}
+		entry = prev
+	}
+
+	if cache.totalCost+cost > cache.maxCost {
+		return ErrCacheFull
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package simple_cache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCostBoundedCache(t *testing.T) {
+
+	ttl := time.Minute
+	cache := NewWithCost(10, Factor, ttl, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	}, func(value interface{}) int64 {
+		return int64(len(value.(string)))
+	})
+	clock := newFakeClock(cache)
+
+	_, err := cache.InsertOrUpdate(1, strings.Repeat("a", 4))
+	assert.NoError(t, err)
+	_, err = cache.InsertOrUpdate(2, strings.Repeat("b", 4))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8), cache.TotalCost())
+	assert.Equal(t, 0.8, cache.FullnessRatio())
+
+	// the live entries are not evicted to make room
+	_, err = cache.InsertOrUpdate(3, strings.Repeat("c", 3))
+	assert.ErrorIs(t, err, ErrCacheFull)
+	assert.Equal(t, int64(8), cache.TotalCost())
+	assert.Equal(t, 2, cache.NumEntries())
+
+	// an update accounts the difference
+	_, err = cache.InsertOrUpdate(1, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), cache.TotalCost())
+	_, err = cache.InsertOrUpdate(3, strings.Repeat("c", 3))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8), cache.TotalCost())
+	_, err = cache.InsertOrUpdate(1, strings.Repeat("a", 4))
+	assert.ErrorIs(t, err, ErrCacheFull)
+	value, err := cache.Read(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", value)
+
+	_, err = cache.InsertOrUpdate(4, strings.Repeat("d", 11))
+	assert.ErrorIs(t, err, ErrCostTooHigh)
+
+	// the expired entries are reclaimed to make room
+	clock.Advance(ttl)
+	_, err = cache.InsertOrUpdate(4, strings.Repeat("d", 10))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), cache.TotalCost())
+	assert.Equal(t, 1, cache.NumEntries())
+	assert.Equal(t, 3, cache.ExpiredCount())
+
+	deleted, err := cache.Delete(4)
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+	assert.Equal(t, int64(0), cache.TotalCost())
+
+	_, err = cache.InsertOrUpdate(5, "e")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), cache.Clone().TotalCost())
+	assert.NoError(t, cache.Clean())
+	assert.Equal(t, int64(0), cache.TotalCost())
+}
+
+func TestCostBoundedInsertIfRoom(t *testing.T) {
+
+	ttl := time.Minute
+	cache := NewWithCost(10, Factor, ttl, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	}, func(value interface{}) int64 {
+		return int64(len(value.(string)))
+	})
+	clock := newFakeClock(cache)
+
+	for i := 1; i <= 2; i++ {
+		inserted, err := cache.InsertIfRoom(i, strings.Repeat("a", 5))
+		assert.NoError(t, err)
+		assert.True(t, inserted)
+	}
+
+	// growing an existing value does not evict live entries either
+	inserted, err := cache.InsertIfRoom(1, strings.Repeat("a", 8))
+	assert.NoError(t, err)
+	assert.False(t, inserted)
+	assert.Equal(t, int64(10), cache.TotalCost())
+	assert.Equal(t, 2, cache.NumEntries())
+
+	inserted, err = cache.InsertIfRoom(1, "a")
+	assert.NoError(t, err)
+	assert.True(t, inserted)
+
+	// but the expired entries are reclaimed to make room
+	clock.Advance(ttl)
+	_, err = cache.InsertOrUpdate(1, "a")
+	assert.NoError(t, err)
+	inserted, err = cache.InsertIfRoom(1, strings.Repeat("a", 8))
+	assert.NoError(t, err)
+	assert.True(t, inserted)
+	assert.Equal(t, int64(8), cache.TotalCost())
+	assert.Equal(t, 1, cache.NumEntries())
+}
@@ -0,0 +1,93 @@
+package simple_cache
+
+import (
+	"math"
+	"time"
+)
+
+type distinctKeysTracker struct {
+	hll hyperLogLog
+
+	// alert on the rate of new distinct keys; disabled if fn is nil
+	window        time.Duration
+	maxNewKeys    int
+	fn            func(newKeys int)
+	windowStart   time.Time
+	startEstimate float64
+}
+
+// EnableDistinctKeys Start tracking the distinct keys handled by InsertOrUpdate and Read. The
+// tracking is disabled by default because it takes 16KB and hashes every key with the internal lock
+// taken. Calling it again has no effect
+func (cache *SimpleCache) EnableDistinctKeys() {
+
+	cache.writeLock()
+	defer cache.lock.Unlock()
+
+	cache.enableDistinctKeys()
+}
+
+// Create the tracker if it does not exist yet; mutex must be taken
+func (cache *SimpleCache) enableDistinctKeys() {
+	if cache.distinctKeys == nil {
+		cache.distinctKeys = &distinctKeysTracker{}
+	}
+}
+
+// DistinctKeysSeen Return an estimate (HyperLogLog, ~1% error) of the number of distinct keys
+// handled by InsertOrUpdate and Read since the tracking was enabled, including keys that are no
+// longer in the cache. A value growing without bound while NumEntries stays flat usually means that
+// the keys include something unique per request, such as a timestamp or a request id. Clean does not
+// reset it. Reads of pinned keys are not recorded. Return 0 if the tracking is not enabled
+func (cache *SimpleCache) DistinctKeysSeen() int {
+
+	cache.lock.RLock()
+	defer cache.lock.RUnlock()
+
+	if cache.distinctKeys == nil {
+		return 0
+	}
+	return int(math.Round(cache.distinctKeys.hll.estimate()))
+}
+
+// SetDistinctKeysAlert Call fn when more than maxNewKeys new distinct keys are seen within a window.
+// The check is done on the first key recorded after each window elapses, and fn receives the
+// estimated number of new keys in the elapsed window. fn is called without holding the internal lock.
+// A nil fn disables the alert. It enables the tracking of distinct keys, if not already enabled
+func (cache *SimpleCache) SetDistinctKeysAlert(window time.Duration, maxNewKeys int, fn func(newKeys int)) {
+
+	cache.writeLock()
+	defer cache.lock.Unlock()
+
+	cache.enableDistinctKeys()
+	tracker := cache.distinctKeys
+	tracker.window = window
+	tracker.maxNewKeys = maxNewKeys
+	tracker.fn = fn
+	tracker.windowStart = cache.now()
+	tracker.startEstimate = tracker.hll.estimate()
+}
+
+// Record that stringKey has been handled, if the tracking is enabled; mutex must be taken
+func (cache *SimpleCache) recordKey(stringKey string, currTime time.Time) {
+
+	if cache.distinctKeys == nil {
+		return
+	}
+	tracker := cache.distinctKeys
+	tracker.hll.add(stringKey)
+
+	if tracker.fn == nil || currTime.Sub(tracker.windowStart) < tracker.window {
+		return
+	}
+
+	estimate := tracker.hll.estimate()
+	newKeys := int(math.Round(estimate - tracker.startEstimate))
+	tracker.windowStart = currTime
+	tracker.startEstimate = estimate
+
+	if newKeys > tracker.maxNewKeys {
+		fn := tracker.fn
+		cache.pendingCallbacks = append(cache.pendingCallbacks, func() { fn(newKeys) })
+	}
+}
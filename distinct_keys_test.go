@@ -0,0 +1,66 @@
+package simple_cache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDistinctKeysSeen(t *testing.T) {
+
+	cache := New(Capacity, Factor, time.Hour, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	_, _ = cache.InsertOrUpdate(-1, -1)
+	assert.Equal(t, 0, cache.DistinctKeysSeen()) // disabled by default
+	assert.Nil(t, cache.distinctKeys)
+
+	cache.EnableDistinctKeys()
+	const numKeys = 20000
+	for i := 0; i < numKeys; i++ {
+		_, _ = cache.InsertOrUpdate(i, i)
+		_, _ = cache.Read(i % 50) // repeated keys do not count
+	}
+	assert.Equal(t, Capacity, cache.NumEntries())
+	assert.InEpsilon(t, numKeys, cache.DistinctKeysSeen(), 0.03)
+
+	small := New(Capacity, Factor, time.Hour, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	small.EnableDistinctKeys()
+	for i := 0; i < 100; i++ {
+		_, _ = small.Read(i % 10)
+	}
+	assert.Equal(t, 10, small.DistinctKeysSeen())
+}
+
+func TestDistinctKeysAlert(t *testing.T) {
+
+	window := 20 * time.Millisecond
+	cache := New(Capacity, Factor, time.Hour, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	clock := newFakeClock(cache)
+
+	var alerts []int
+	cache.SetDistinctKeysAlert(window, 100, func(newKeys int) {
+		alerts = append(alerts, newKeys)
+		assert.True(t, cache.DistinctKeysSeen() > 0) // called without the lock
+	})
+
+	for i := 0; i < 50; i++ {
+		_, _ = cache.Read(i)
+	}
+	clock.Advance(window)
+	_, _ = cache.Read(0)
+	assert.Empty(t, alerts)
+
+	for i := 1000; i < 1500; i++ {
+		_, _ = cache.Read(i)
+	}
+	clock.Advance(window)
+	_, _ = cache.Read(0)
+	assert.Len(t, alerts, 1)
+	assert.InEpsilon(t, 500, alerts[0], 0.05)
+}
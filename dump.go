@@ -0,0 +1,144 @@
+package simple_cache
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"io"
+)
+
+const dumpVersion = 1
+
+// First record of a Dump stream
+type dumpHeader struct {
+	Version    int
+	Compressed bool // whether the values are the bytes stored by a cache in compression mode
+	Count      int
+}
+
+// Each following record of a Dump stream, from lru to mru
+type dumpEntry struct {
+	Key        string
+	Bytes      []byte      // the stored bytes, in compression mode
+	Value      interface{} // the value otherwise, or if it was stored raw because its serialization failed
+	Raw        bool
+	Timestamp  int64 // unix nanoseconds
+	Expiration int64 // unix nanoseconds
+	TTL        int64 // nanoseconds
+	Order      int64
+}
+
+// Dump Write the live entries to w as a gob stream, from lru to mru, with their timestamps,
+// expiration times and ttls, so that Load can warm up another cache after a restart.
+//
+// In compression mode the stored bytes (serialized, compressed and encrypted if so configured) are
+// written, so the values do not need to be serializable by gob. Otherwise, and for the values stored
+// raw because their serialization failed, the values themselves are written and their concrete types
+// must have been registered with gob.Register, except the predeclared ones. Pinned keys and spilled
+// entries are not dumped.
+//
+// Uses internal lock. The entries are collected under the lock and encoded without it
+func (cache *SimpleCache) Dump(w io.Writer) error {
+
+	var entries []dumpEntry
+	func() {
+		defer cache.lock.Unlock()
+		cache.writeLock()
+
+		currTime := cache.now()
+		for entry := cache.head.prev; entry != &cache.head; entry = entry.prev {
+			if entry.state != BUSY || entry.hasExpired(currTime) {
+				continue
+			}
+			dumped := dumpEntry{
+				Key:        entry.key,
+				Raw:        entry.raw,
+				Timestamp:  entry.timestamp.UnixNano(),
+				Expiration: entry.expirationTime().UnixNano(),
+				TTL:        int64(entry.ttl),
+				Order:      entry.order,
+			}
+			if cache.toCompress && !entry.raw {
+				dumped.Bytes = entry.value.([]byte) // stored values are never modified in place
+			} else {
+				dumped.Value = entry.value
+			}
+			entries = append(entries, dumped)
+		}
+	}()
+
+	out := bufio.NewWriter(w)
+	encoder := gob.NewEncoder(out)
+
+	err := encoder.Encode(&dumpHeader{Version: dumpVersion, Compressed: cache.toCompress, Count: len(entries)})
+	if err != nil {
+		return err
+	}
+	for i := range entries {
+		if err := encoder.Encode(&entries[i]); err != nil {
+			return fmt.Errorf("cannot dump stringficated key %s: %w", entries[i].Key, err)
+		}
+	}
+
+	return out.Flush()
+}
+
+// Load Read a stream written by Dump and insert its entries from lru to mru, so their relative lru
+// order is preserved, replacing the entries with the same key. The entries keep the expiration time
+// they had when dumped; the ones expired meanwhile are skipped. The whole stream is read before
+// touching the cache, so a stream that cannot be decoded, or that was dumped from a cache with
+// another mode (compression or not), is rejected with an error wrapping ErrBadSnapshot and the cache
+// is left as it was.
+//
+// In compression mode the bytes are stored as they are, so the cache must be configured with the
+// same serialization and encryption as the dumped one. If the cache fills up with live entries, the
+// load stops with ErrCacheFull. Counters are not changed.
+//
+// Uses internal lock
+func (cache *SimpleCache) Load(r io.Reader) error {
+
+	decoder := gob.NewDecoder(bufio.NewReader(r))
+
+	var header dumpHeader
+	if err := decoder.Decode(&header); err != nil {
+		return fmt.Errorf("%w: cannot read header: %v", ErrBadSnapshot, err)
+	}
+	if header.Version != dumpVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrBadSnapshot, header.Version)
+	}
+	if header.Compressed != cache.toCompress {
+		return fmt.Errorf("%w: dumped with compression %t, but the cache has compression %t",
+			ErrBadSnapshot, header.Compressed, cache.toCompress)
+	}
+
+	if header.Count < 0 {
+		return fmt.Errorf("%w: negative entry count %d", ErrBadSnapshot, header.Count)
+	}
+
+	// grown progressively, so that a corrupted count does not allocate a huge slice upfront
+	var entries []binaryEntry
+	for i := 0; i < header.Count; i++ {
+		var dumped dumpEntry
+		if err := decoder.Decode(&dumped); err != nil {
+			return fmt.Errorf("%w: cannot read entry %d: %v", ErrBadSnapshot, i, err)
+		}
+		restored := binaryEntry{
+			key:        dumped.Key,
+			value:      dumped.Value,
+			raw:        dumped.Raw,
+			expiration: dumped.Expiration,
+			timestamp:  dumped.Timestamp,
+			ttl:        dumped.TTL,
+			order:      dumped.Order,
+		}
+		if cache.toCompress && !dumped.Raw {
+			restored.value = dumped.Bytes
+		}
+		entries = append(entries, restored)
+	}
+
+	defer cache.unlock()
+	cache.writeLock()
+
+	return cache.restoreEntries(entries)
+}
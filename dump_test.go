@@ -0,0 +1,95 @@
+package simple_cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDumpLoad(t *testing.T) {
+
+	toMapKey := func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	}
+	cache := New(Capacity, Factor, time.Hour, toMapKey)
+	clock := newFakeClock(cache)
+	for i := 0; i < 10; i++ {
+		_, err := cache.InsertOrUpdate(i, "value "+strconv.Itoa(i))
+		assert.NoError(t, err)
+	}
+	_, err := cache.Read(3)
+	assert.NoError(t, err)
+	_, err = cache.InsertOrUpdateWithTTL(10, "short lived", time.Minute)
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.NoError(t, cache.Dump(&buf))
+
+	clock.Advance(time.Minute) // 10 expires before being loaded
+
+	restored := New(Capacity, Factor, time.Hour, toMapKey)
+	restored.SetClock(clock.Now)
+	assert.NoError(t, restored.Load(&buf))
+	assert.Equal(t, 10, restored.NumEntries())
+	assert.Equal(t, cacheKeys(cache)[1:], cacheKeys(restored)) // without 10, the mru
+	assert.Equal(t, cache.table["3"].expirationTime(), restored.table["3"].expirationTime())
+	assert.Equal(t, cache.table["3"].timestamp.UnixNano(), restored.table["3"].timestamp.UnixNano())
+	for i := 0; i < 10; i++ {
+		value, err := restored.Peek(i)
+		assert.NoError(t, err)
+		assert.Equal(t, "value "+strconv.Itoa(i), value)
+	}
+}
+
+func TestDumpLoadCompressed(t *testing.T) {
+
+	cache := newValueTypeCache(Capacity, time.Hour)
+	for i := 0; i < 10; i++ {
+		_, err := cache.InsertOrUpdate(i, &ValueType{Num: i, Text: strconv.Itoa(i)})
+		assert.NoError(t, err)
+	}
+	_, err := cache.Read(0)
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.NoError(t, cache.Dump(&buf))
+	dumped := buf.Bytes()
+
+	restored := newValueTypeCache(Capacity, time.Hour)
+	_, err = restored.InsertOrUpdate(5, &ValueType{Num: 50})
+	assert.NoError(t, err)
+	assert.NoError(t, restored.Load(bytes.NewReader(dumped)))
+	assert.Equal(t, cacheKeys(cache), cacheKeys(restored))
+	for i := 0; i < 10; i++ {
+		value, err := restored.Read(i)
+		assert.NoError(t, err)
+		assert.Equal(t, &ValueType{Num: i, Text: strconv.Itoa(i)}, value)
+	}
+
+	// a dump of a cache in compression mode cannot be loaded in plain mode, nor a truncated one
+	plain := New(Capacity, Factor, time.Hour, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	assert.ErrorIs(t, plain.Load(bytes.NewReader(dumped)), ErrBadSnapshot)
+	empty := newValueTypeCache(Capacity, time.Hour)
+	assert.ErrorIs(t, empty.Load(bytes.NewReader(dumped[:len(dumped)-10])), ErrBadSnapshot)
+	assert.ErrorIs(t, empty.Load(bytes.NewReader([]byte("garbage"))), ErrBadSnapshot)
+	assert.Equal(t, 0, empty.NumEntries())
+}
+
+func TestLoadCorruptedHeader(t *testing.T) {
+
+	cache := New(Capacity, Factor, time.Hour, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+
+	for _, count := range []int{-1, 1 << 62} {
+		var buf bytes.Buffer
+		assert.NoError(t, gob.NewEncoder(&buf).Encode(dumpHeader{Version: dumpVersion, Count: count}))
+		assert.ErrorIs(t, cache.Load(&buf), ErrBadSnapshot)
+	}
+	assert.Equal(t, 0, cache.NumEntries())
+}
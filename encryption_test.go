@@ -0,0 +1,92 @@
+package simple_cache
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newAESGCM(t *testing.T) (func([]byte) ([]byte, error), func([]byte) ([]byte, error)) {
+
+	key := make([]byte, 32)
+	_, err := io.ReadFull(rand.Reader, key)
+	assert.NoError(t, err)
+	block, err := aes.NewCipher(key)
+	assert.NoError(t, err)
+	gcm, err := cipher.NewGCM(block)
+	assert.NoError(t, err)
+
+	encrypt := func(plain []byte) ([]byte, error) {
+		nonce := make([]byte, gcm.NonceSize())
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return nil, err
+		}
+		return gcm.Seal(nonce, nonce, plain, nil), nil
+	}
+	decrypt := func(sealed []byte) ([]byte, error) {
+		if len(sealed) < gcm.NonceSize() {
+			return nil, errors.New("sealed value too short")
+		}
+		nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+		return gcm.Open(nil, nonce, ciphertext, nil)
+	}
+
+	return encrypt, decrypt
+}
+
+func TestEncryption(t *testing.T) {
+
+	encrypt, decrypt := newAESGCM(t)
+	cache := NewWithEncryption(Capacity, Factor, time.Hour,
+		func(key interface{}) (string, error) {
+			return strconv.Itoa(key.(int)), nil
+		}, func(value interface{}) ([]byte, error) {
+			content, ok := value.(*ValueType)
+			if !ok {
+				return nil, errors.New("unexpected value type")
+			}
+			return json.Marshal(content)
+		},
+		func(buf []byte) (interface{}, error) {
+			value := &ValueType{}
+			err := json.Unmarshal(buf, value)
+			return value, err
+		}, encrypt, decrypt)
+
+	const secret = "this is a secret"
+	for i := 0; i < 10; i++ {
+		_, err := cache.InsertOrUpdate(i, &ValueType{Num: i, Text: secret})
+		assert.NoError(t, err)
+	}
+
+	for it := cache.NewCacheIt(); it.HasCurr(); it.Next() {
+		assert.False(t, bytes.Contains(it.GetCurr().value.([]byte), []byte(secret)))
+	}
+
+	for i := 0; i < 10; i++ {
+		value, err := cache.Read(i)
+		assert.NoError(t, err)
+		assert.Equal(t, ValueType{Num: i, Text: secret}, *value.(*ValueType))
+	}
+
+	compressed, err := cache.ReadCompressed(3)
+	assert.NoError(t, err)
+	buf, err := lz4Decompress(compressed)
+	assert.NoError(t, err)
+	assert.Contains(t, string(buf), secret)
+
+	// The raw fallback would keep the value in clear
+	cache.SetSerializationErrorHandler(func(key string, err error) FallbackAction {
+		return StoreRaw
+	})
+	_, err = cache.InsertOrUpdate(100, secret)
+	assert.Error(t, err)
+}
@@ -0,0 +1,69 @@
+package simple_cache
+
+// Ratio below a threshold that the fullness must reach before the threshold can fire again
+const fillHysteresis = 0.05
+
+type fillThreshold struct {
+	threshold float64
+	fn        func(ratio float64)
+	armed     bool // false after firing, until the ratio goes below threshold - fillHysteresis
+}
+
+// FullnessRatio Return numEntries / capacity, or TotalCost / maxCost if the cache is bounded by cost
+func (cache *SimpleCache) FullnessRatio() float64 {
+
+	cache.lock.RLock()
+	defer cache.lock.RUnlock()
+
+	return cache.fullnessRatio()
+}
+
+func (cache *SimpleCache) fullnessRatio() float64 {
+	if cache.maxCost > 0 {
+		return float64(cache.totalCost) / float64(cache.maxCost)
+	}
+	if cache.capacity <= 0 {
+		return 0
+	}
+	return float64(cache.numEntries) / float64(cache.capacity)
+}
+
+// OnFillThreshold Register fn to be called each time the fullness ratio (see FullnessRatio)
+// crosses threshold upward. fn receives the ratio that reached the threshold.
+//
+// Once fired, the threshold is not fired again until the ratio goes below threshold - 0.05, so
+// that a cache oscillating around the threshold does not flap. If the ratio is already above
+// threshold at registration time, fn is not called until the next crossing.
+//
+// Several thresholds can be registered. fn is called without holding the internal lock, so it can
+// use the cache
+func (cache *SimpleCache) OnFillThreshold(threshold float64, fn func(ratio float64)) {
+
+	cache.writeLock()
+	defer cache.lock.Unlock()
+
+	cache.fillThresholds = append(cache.fillThresholds, &fillThreshold{
+		threshold: threshold,
+		fn:        fn,
+		armed:     cache.fullnessRatio() < threshold,
+	})
+}
+
+// Queue the callbacks of the crossed thresholds; mutex must be taken. Must be called each time numEntries or totalCost changes
+func (cache *SimpleCache) checkFillThresholds() {
+
+	if len(cache.fillThresholds) == 0 {
+		return
+	}
+
+	ratio := cache.fullnessRatio()
+	for _, t := range cache.fillThresholds {
+		if t.armed && ratio >= t.threshold {
+			t.armed = false
+			fn := t.fn
+			cache.pendingCallbacks = append(cache.pendingCallbacks, func() { fn(ratio) })
+		} else if !t.armed && ratio < t.threshold-fillHysteresis {
+			t.armed = true
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package simple_cache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestOnFillThreshold(t *testing.T) {
+
+	cache := New(Capacity, Factor, time.Hour, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+
+	var fired80, fired95 []float64
+	cache.OnFillThreshold(0.8, func(ratio float64) {
+		// Runs outside the lock, so using the cache must not deadlock
+		assert.Equal(t, ratio, cache.FullnessRatio())
+		fired80 = append(fired80, ratio)
+	})
+	cache.OnFillThreshold(0.95, func(ratio float64) {
+		fired95 = append(fired95, ratio)
+	})
+
+	for i := 0; i < 90; i++ {
+		_, err := cache.InsertOrUpdate(i, i)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []float64{0.8}, fired80)
+	assert.Empty(t, fired95)
+
+	// Going down and up again inside the hysteresis window does not fire
+	assert.NoError(t, cache.PinImmutable(0))
+	assert.NoError(t, cache.PinImmutable(1))
+	for i := 0; i < 12; i++ {
+		assert.NoError(t, cache.PinImmutable(i+2))
+	}
+	assert.InDelta(t, 0.76, cache.FullnessRatio(), 1e-9)
+	for i := 100; i < 120; i++ {
+		_, err := cache.InsertOrUpdate(i, i)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []float64{0.8}, fired80)
+	assert.Equal(t, []float64{0.95}, fired95)
+
+	assert.NoError(t, cache.Clean())
+	for i := 0; i < 80; i++ {
+		_, err := cache.InsertOrUpdate(1000+i, i)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []float64{0.8, 0.8}, fired80)
+}
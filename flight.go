@@ -0,0 +1,77 @@
+package simple_cache
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Execution in progress of a function for a key
+type flightCall struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// flightGroup coalesces the concurrent executions of a function for the same key: only the first
+// caller runs it, the others wait for and receive its result (single flight)
+type flightGroup struct {
+	lock      sync.Mutex
+	calls     map[string]*flightCall
+	coalesced int // callers that waited for a call in progress instead of running fn
+}
+
+// Return the keys with a call in progress, in no particular order
+func (group *flightGroup) keys() []string {
+
+	group.lock.Lock()
+	defer group.lock.Unlock()
+
+	keys := make([]string, 0, len(group.calls))
+	for key := range group.calls {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Return the number of callers that joined a call in progress
+func (group *flightGroup) coalescedCount() int {
+
+	group.lock.Lock()
+	defer group.lock.Unlock()
+
+	return group.coalesced
+}
+
+// Run fn for key, unless there is already a call in progress for key, in which case wait for its
+// result. A panic in fn is recovered and returned as an error to every caller
+func (group *flightGroup) do(key string, fn func() (interface{}, error)) (value interface{}, err error) {
+
+	group.lock.Lock()
+	if call, ok := group.calls[key]; ok {
+		group.coalesced++
+		group.lock.Unlock()
+		<-call.done
+		return call.value, call.err
+	}
+	if group.calls == nil {
+		group.calls = make(map[string]*flightCall)
+	}
+	call := &flightCall{done: make(chan struct{})}
+	group.calls[key] = call
+	group.lock.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			call.value, call.err = nil, fmt.Errorf("call for key %s panicked: %v", key, r)
+			value, err = call.value, call.err
+		}
+		group.lock.Lock()
+		delete(group.calls, key)
+		group.lock.Unlock()
+		close(call.done)
+	}()
+
+	call.value, call.err = fn()
+
+	return call.value, call.err
+}
@@ -0,0 +1,56 @@
+package simple_cache
+
+import "errors"
+
+// GetOrCompute Return the value of key as Read does if it is live. Otherwise, if it is not in the
+// cache or has expired, call compute, insert its result with the ttl of the cache and return it.
+//
+// Concurrent calls for the same key run compute only once: the other callers wait and receive the
+// same result (single flight), which prevents a stampede on the upstream when a hot key expires.
+// An error returned by compute, or a panic in it, is returned to all of them and nothing is
+// inserted, so the next call computes again. An expired entry stays in the cache until then. The
+// computed value replaces an expired entry even under the Reject resurrect policy
+func (cache *SimpleCache) GetOrCompute(key interface{}, compute func() (interface{}, error)) (interface{}, error) {
+
+	value, err := cache.Read(key)
+	if err == nil {
+		return value, nil
+	}
+	if !errors.Is(err, ErrKeyExpired) && !errors.Is(err, ErrKeyNotFound) {
+		return nil, err
+	}
+
+	stringKey, err := cache.toMapKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cache.reloads.do(stringKey, func() (interface{}, error) {
+		// a flight for key could have finished between the Read and this one
+		if value, err := cache.Peek(key); err == nil {
+			return value, nil
+		}
+		value, err := compute()
+		if err != nil {
+			return nil, err
+		}
+		if _, err = cache.insertOrUpdate(key, value, insertOptions{ttl: cache.ttl, refresh: true}); err != nil {
+			return nil, err
+		}
+		return value, nil
+	})
+}
+
+// InflightComputations Return the stringficated keys whose compute, or reload of an expired entry
+// with a reloader, is in progress, in no particular order. A key staying here for long usually means
+// a compute that does not return. The list is bounded by the number of concurrent computations
+func (cache *SimpleCache) InflightComputations() []string {
+	return cache.reloads.keys()
+}
+
+// CoalescedComputations Return the number of calls of GetOrCompute, and of reads of expired entries
+// with a reloader, that waited for the computation already in progress for their key instead of
+// running their own. Each one is a call to the upstream saved by the single flight
+func (cache *SimpleCache) CoalescedComputations() int {
+	return cache.reloads.coalescedCount()
+}
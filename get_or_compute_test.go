@@ -0,0 +1,136 @@
+package simple_cache
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrCompute(t *testing.T) {
+
+	ttl := 100 * time.Millisecond
+	cache := New(Capacity, Factor, ttl, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	clock := newFakeClock(cache)
+
+	var computes int32
+	release := make(chan struct{})
+	compute := func() (interface{}, error) {
+		<-release
+		return int(atomic.AddInt32(&computes, 1)) * 10, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := cache.GetOrCompute(1, compute)
+			assert.NoError(t, err)
+			assert.Equal(t, 10, value)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, []string{"1"}, cache.InflightComputations())
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&computes))
+	assert.Empty(t, cache.InflightComputations())
+	assert.Equal(t, 9, cache.CoalescedComputations())
+
+	// live: compute is not called
+	value, err := cache.GetOrCompute(1, compute)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, value)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&computes))
+
+	// expired: computed again with a fresh ttl
+	clock.Advance(ttl)
+	value, err = cache.GetOrCompute(1, compute)
+	assert.NoError(t, err)
+	assert.Equal(t, 20, value)
+	value, err = cache.Read(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 20, value)
+}
+
+func TestGetOrComputeFailures(t *testing.T) {
+
+	cache := New(Capacity, Factor, time.Hour, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cache.GetOrCompute(1, func() (interface{}, error) {
+				<-release
+				panic("upstream exploded")
+			})
+			assert.ErrorContains(t, err, "upstream exploded")
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	_, err := cache.GetOrCompute(1, func() (interface{}, error) {
+		return nil, errors.New("backend down")
+	})
+	assert.EqualError(t, err, "backend down")
+
+	assert.Equal(t, 0, cache.NumEntries())
+	_, err = cache.Read(1)
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	value, err := cache.GetOrCompute(1, func() (interface{}, error) {
+		return 1, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+}
+
+func TestLoadsWithRejectPolicy(t *testing.T) {
+
+	ttl := time.Minute
+	cache := New(Capacity, Factor, ttl, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	clock := newFakeClock(cache)
+	cache.SetResurrectPolicy(Reject)
+	cache.CoalesceMisses(time.Nanosecond, time.Second)
+
+	loads := 0
+	loader := func() (interface{}, error) {
+		loads++
+		return loads, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err := cache.InsertOrUpdate(i, -1)
+		assert.NoError(t, err)
+	}
+	clock.Advance(ttl)
+
+	// each expired key is loaded once and then served from the cache
+	for i := 0; i < 3; i++ {
+		value, err := cache.GetOrCompute(0, loader)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, value)
+		value, _, err = cache.ReadSmart(1, 0, loader)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, value)
+		value, err = cache.ReadCoalesced(2, loader)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, value)
+	}
+	assert.Equal(t, 3, loads)
+}
@@ -0,0 +1,39 @@
+package simple_cache
+
+// GroupBy Partition the live entries (pinned keys included) into buckets named by
+// bucket(key, value), and return the stringficated keys of each bucket, from mru to lru.
+//
+// The entries are collected in a single pass with the internal lock taken, so the grouping is a
+// consistent snapshot of the cache. bucket is called afterwards, without the lock, with the
+// decoded value in compression mode; entries that cannot be decoded are skipped. It neither
+// refreshes the entries nor changes counters
+func (cache *SimpleCache) GroupBy(bucket func(key string, value interface{}) string) map[string][]string {
+
+	var found []storedValue
+	func() {
+		defer cache.lock.Unlock()
+		cache.writeLock()
+
+		currTime := cache.now()
+		for entry := cache.head.next; entry != &cache.head; entry = entry.next {
+			if entry.state == BUSY && !entry.hasExpired(currTime) {
+				found = append(found, entry.stored())
+			}
+		}
+		for key, holder := range cache.pinnedImmutables() {
+			found = append(found, storedValue{key: key, value: holder.Load().(*pinnedValue).value, raw: true})
+		}
+	}()
+
+	groups := make(map[string][]string)
+	for _, stored := range found {
+		value, err := cache.decodeStored(stored)
+		if err != nil {
+			continue
+		}
+		name := bucket(stored.key, value)
+		groups[name] = append(groups[name], stored.key)
+	}
+
+	return groups
+}
@@ -0,0 +1,67 @@
+package simple_cache
+
+import (
+	"math"
+	"math/bits"
+)
+
+const hllPrecision = 14 // 2^14 registers of one byte: ~0.8% standard error
+const hllRegisters = 1 << hllPrecision
+
+// HyperLogLog estimator of the number of distinct strings added to it, with bounded memory
+type hyperLogLog struct {
+	registers [hllRegisters]uint8
+}
+
+func (hll *hyperLogLog) add(s string) {
+	x := mix64(fnv64a(s))
+
+	idx := x >> (64 - hllPrecision)
+	w := x<<hllPrecision | 1<<(hllPrecision-1) // guard bit bounds the rank
+	rank := uint8(bits.LeadingZeros64(w)) + 1
+	if rank > hll.registers[idx] {
+		hll.registers[idx] = rank
+	}
+}
+
+func (hll *hyperLogLog) estimate() float64 {
+
+	m := float64(hllRegisters)
+	alpha := 0.7213 / (1 + 1.079/m)
+
+	sum, zeros := 0.0, 0
+	for _, r := range hll.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	estimate := alpha * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros)) // linear counting for small cardinalities
+	}
+
+	return estimate
+}
+
+// FNV-1a without allocating a hash.Hash64
+func fnv64a(s string) uint64 {
+	const offset64, prime64 = 14695981039346656037, 1099511628211
+	h := uint64(offset64)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= prime64
+	}
+	return h
+}
+
+// splitmix64 finalizer. fnv alone does not spread short keys well enough over the 64 bits
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,138 @@
+package simple_cache
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// Value holder stored into the atomic.Value of a pinned key. It guarantees that every Store
+// receives the same concrete type, whatever the type of the cached value is
+type pinnedValue struct {
+	value interface{}
+}
+
+// Return the pinned keys map. It is never modified, so it can be read without lock
+func (cache *SimpleCache) pinnedImmutables() map[string]*atomic.Value {
+	pinned, _ := cache.immutables.Load().(map[string]*atomic.Value)
+	return pinned
+}
+
+// PinImmutable Move key out of the lru list and keep its value in an atomic.Value, so that
+// Read can retrieve it without taking any lock. Intended for a small set of hot, config-like
+// keys.
+//
+// A pinned key never expires nor is evicted, and its reads are not counted as hits. Updating it
+// through InsertOrUpdate takes the lock and atomically swaps the value. In compression mode the
+// value is kept decompressed.
+//
+// Return error if the key stringification fails or if the key is not in the cache or has expired
+func (cache *SimpleCache) PinImmutable(key interface{}) error {
+
+	stringKey, err := cache.toMapKey(key)
+	if err != nil {
+		return err
+	}
+
+	defer cache.unlock()
+	cache.writeLock()
+
+	pinned := cache.pinnedImmutables()
+	if _, ok := pinned[stringKey]; ok {
+		return nil // already pinned
+	}
+
+	entry := cache.table[stringKey]
+	if entry == nil || entry.state == AVAILABLE || entry.hasExpired(cache.now()) {
+		return fmt.Errorf("stringficated key %s not found or expired", stringKey)
+	}
+
+	value := entry.value
+	if cache.toCompress && !entry.raw {
+		value, err = cache.decodeValue(entry.value.([]byte))
+		if err != nil {
+			return err
+		}
+	}
+
+	holder := &atomic.Value{}
+	holder.Store(&pinnedValue{value: value})
+
+	newPinned := make(map[string]*atomic.Value, len(pinned)+1)
+	for k, v := range pinned {
+		newPinned[k] = v
+	}
+	newPinned[stringKey] = holder
+	cache.immutables.Store(newPinned)
+
+	cache.removeEntry(entry)
+
+	return nil
+}
+
+// UnpinImmutable Return a pinned key to the regular cache management. The key is inserted again
+// as the MRU entry with a fresh ttl. Return false if the key was not pinned
+func (cache *SimpleCache) UnpinImmutable(key interface{}) (bool, error) {
+
+	stringKey, err := cache.toMapKey(key)
+	if err != nil {
+		return false, err
+	}
+
+	cache.writeLock()
+	holder := cache.unpinImmutable(stringKey)
+	cache.lock.Unlock()
+
+	if holder == nil {
+		return false, nil
+	}
+
+	_, err = cache.InsertOrUpdate(key, holder.Load().(*pinnedValue).value)
+	return true, err
+}
+
+// Remove stringKey from the pinned keys and return its holder, or nil if it was not pinned; mutex must be taken
+func (cache *SimpleCache) unpinImmutable(stringKey string) *atomic.Value {
+
+	pinned := cache.pinnedImmutables()
+	holder, ok := pinned[stringKey]
+	if !ok {
+		return nil
+	}
+
+	newPinned := make(map[string]*atomic.Value, len(pinned))
+	for k, v := range pinned {
+		if k != stringKey {
+			newPinned[k] = v
+		}
+	}
+	cache.immutables.Store(newPinned)
+
+	return holder
+}
+
+// Lock free read of a pinned key
+func (cache *SimpleCache) readImmutable(stringKey string) (interface{}, bool) {
+	holder, ok := cache.pinnedImmutables()[stringKey]
+	if !ok {
+		return nil, false
+	}
+	return holder.Load().(*pinnedValue).value, true
+}
+
+// Slow path of InsertOrUpdate for a pinned key. Return false if the key is not pinned
+func (cache *SimpleCache) updateImmutable(stringKey string, value interface{}) bool {
+
+	if _, ok := cache.pinnedImmutables()[stringKey]; !ok {
+		return false // fast check without lock for the common case
+	}
+
+	defer cache.lock.Unlock()
+	cache.writeLock()
+
+	holder, ok := cache.pinnedImmutables()[stringKey]
+	if !ok {
+		return false // unpinned meanwhile
+	}
+	holder.Store(&pinnedValue{value: value})
+	return true
+}
@@ -0,0 +1,69 @@
+package simple_cache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPinImmutable(t *testing.T) {
+
+	cache := New(Capacity, Factor, time.Hour, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+
+	assert.Error(t, cache.PinImmutable(1))
+
+	for i := 0; i < 10; i++ {
+		_, err := cache.InsertOrUpdate(i, i)
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, cache.PinImmutable(5))
+	assert.Equal(t, 9, cache.NumEntries())
+
+	hits := cache.HitCount()
+	value, err := cache.Read(5)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, value)
+	assert.Equal(t, hits, cache.HitCount())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				value, err := cache.Read(5)
+				assert.NoError(t, err)
+				assert.GreaterOrEqual(t, value.(int), 5)
+			}
+		}()
+	}
+	for j := 0; j < 100; j++ {
+		_, err = cache.InsertOrUpdate(5, 5+j)
+		assert.NoError(t, err)
+	}
+	wg.Wait()
+
+	value, err = cache.Read(5)
+	assert.NoError(t, err)
+	assert.Equal(t, 104, value)
+	assert.Equal(t, 9, cache.NumEntries())
+
+	unpinned, err := cache.UnpinImmutable(5)
+	assert.NoError(t, err)
+	assert.True(t, unpinned)
+	assert.Equal(t, 10, cache.NumEntries())
+
+	key, value, err := cache.GetMRU()
+	assert.NoError(t, err)
+	assert.Equal(t, "5", key)
+	assert.Equal(t, 104, value)
+
+	unpinned, err = cache.UnpinImmutable(5)
+	assert.NoError(t, err)
+	assert.False(t, unpinned)
+}
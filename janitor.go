@@ -0,0 +1,145 @@
+package simple_cache
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// Maximum number of entries examined by the janitor each time it takes the lock
+const janitorBatchSize = 256
+
+type janitor struct {
+	stop chan struct{} // closed to ask the goroutine to exit
+	done chan struct{} // closed by the goroutine when it exits
+}
+
+// StartJanitor Start a goroutine that every interval reclaims the expired entries, so NumEntries
+// reflects the live data and the memory of idle keys is released. Each sweep walks from the lru end
+// and stops at the first live entry, so an expired entry preceded by a live one, which can happen
+// with per-entry ttls, waits for a later sweep or for an insertion to reclaim it. The entries left by
+// Clean are dropped too, and so are the expired entries of the disk tier. The lock is taken for
+// batches of entries, not for the whole sweep. The reclaimed entries are notified to OnEvict with
+// EvictExpired.
+//
+// Starting a janitor stops the previous one, if any. Return error if interval is not positive
+func (cache *SimpleCache) StartJanitor(interval time.Duration) error {
+	return cache.StartJanitorWithJitter(interval, 0)
+}
+
+// StartJanitorWithJitter Like StartJanitor, but each wait between sweeps is drawn at random within
+// interval +/- jitter * interval, so the janitors of many caches started with the same interval do
+// not wake at the same time. jitter must be in [0, 1); 0 is the fixed interval of StartJanitor.
+// Each cache still runs its own janitor goroutine
+func (cache *SimpleCache) StartJanitorWithJitter(interval time.Duration, jitter float64) error {
+
+	if interval <= 0 {
+		return errors.New("the janitor interval should be positive")
+	}
+	if jitter < 0 || jitter >= 1 {
+		return errors.New("the janitor jitter should be in [0, 1)")
+	}
+
+	cache.StopJanitor()
+
+	j := &janitor{stop: make(chan struct{}), done: make(chan struct{})}
+	cache.writeLock()
+	previous := cache.janitor
+	cache.janitor = j
+	cache.lock.Unlock()
+	if previous != nil { // started concurrently by another call
+		previous.shutdown()
+	}
+
+	go func() {
+		defer close(j.done)
+		timer := time.NewTimer(jittered(interval, jitter))
+		defer timer.Stop()
+		for {
+			select {
+			case <-j.stop:
+				return
+			case <-timer.C:
+				cache.sweepExpired(j.stop)
+				timer.Reset(jittered(interval, jitter))
+			}
+		}
+	}()
+
+	return nil
+}
+
+// StopJanitor Stop the janitor started by StartJanitor and wait for its goroutine to exit. It does
+// nothing if no janitor is running
+func (cache *SimpleCache) StopJanitor() {
+
+	cache.writeLock()
+	j := cache.janitor
+	cache.janitor = nil
+	cache.lock.Unlock()
+
+	if j != nil {
+		j.shutdown()
+	}
+}
+
+// PruneExpired Remove all the expired entries now, wherever they are in the lru list, and return how
+// many were removed. Unlike the janitor, the whole list is swept at once under the lock. The removed
+// entries are notified to OnEvict with EvictExpired. The expired entries of the disk tier, if any,
+// are deleted too, but they are neither counted nor notified.
+//
+// Uses internal lock
+func (cache *SimpleCache) PruneExpired() int {
+
+	cache.writeLock()
+	defer cache.unlock()
+
+	return cache.removeExpired(cache.now(), nil)
+}
+
+// Return a random duration within interval +/- jitter * interval
+func jittered(interval time.Duration, jitter float64) time.Duration {
+	if jitter == 0 {
+		return interval
+	}
+	return interval + time.Duration((2*rand.Float64()-1)*jitter*float64(interval))
+}
+
+func (j *janitor) shutdown() {
+	close(j.stop)
+	<-j.done
+}
+
+// Remove the expired entries from the lru end until a live one is found, taking the lock per batch
+func (cache *SimpleCache) sweepExpired(stop chan struct{}) {
+
+	for done := false; !done; {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		func() {
+			cache.writeLock()
+			defer cache.unlock()
+
+			currTime := cache.now()
+			for i := 0; i < janitorBatchSize; i++ {
+				entry := cache.head.prev
+				if entry == &cache.head || (entry.state == BUSY && !entry.hasExpired(currTime)) {
+					done = true
+					return
+				}
+				cache.evicted(entry, EvictExpired)
+				cache.removeEntry(entry)
+			}
+		}()
+	}
+
+	cache.writeLock()
+	defer cache.unlock()
+	if cache.spill != nil {
+		cache.spill.removeExpired(cache.now())
+	}
+}
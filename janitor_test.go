@@ -0,0 +1,114 @@
+package simple_cache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestJanitor(t *testing.T) {
+
+	ttl := 50 * time.Millisecond
+	cache := New(2*janitorBatchSize, Factor, ttl, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	clock := newFakeClock(cache)
+
+	for i := 0; i < 2*janitorBatchSize-1; i++ {
+		_, err := cache.InsertOrUpdate(i, i)
+		assert.NoError(t, err)
+	}
+	_, err := cache.InsertOrUpdateWithTTL(-1, -1, time.Hour)
+	assert.NoError(t, err)
+
+	assert.Error(t, cache.StartJanitor(0))
+	assert.NoError(t, cache.StartJanitor(10*time.Millisecond))
+	first := cache.janitor
+	assert.NoError(t, cache.StartJanitor(10*time.Millisecond))
+	<-first.done // the first janitor has exited
+
+	clock.Advance(ttl)
+	assert.Eventually(t, func() bool {
+		return cache.FullnessRatio() == 1/float64(cache.Capacity())
+	}, time.Second, 10*time.Millisecond)
+	cache.StopJanitor()
+	assert.Nil(t, cache.janitor)
+
+	assert.Equal(t, 1, cache.NumEntries())
+	assert.Equal(t, []string{"-1"}, cacheKeys(cache))
+	value, err := cache.Read(-1)
+	assert.NoError(t, err)
+	assert.Equal(t, -1, value)
+
+	cache.StopJanitor() // no janitor running
+}
+
+func TestJanitorWithJitter(t *testing.T) {
+
+	ttl := 50 * time.Millisecond
+	cache := New(Capacity, Factor, ttl, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	clock := newFakeClock(cache)
+
+	assert.Error(t, cache.StartJanitorWithJitter(10*time.Millisecond, -0.1))
+	assert.Error(t, cache.StartJanitorWithJitter(10*time.Millisecond, 1))
+
+	for i := 0; i < 10; i++ {
+		d := jittered(10*time.Millisecond, 0.5)
+		assert.True(t, d >= 5*time.Millisecond && d <= 15*time.Millisecond)
+	}
+	assert.Equal(t, 10*time.Millisecond, jittered(10*time.Millisecond, 0))
+
+	for i := 0; i < 10; i++ {
+		_, err := cache.InsertOrUpdate(i, i)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, cache.StartJanitorWithJitter(10*time.Millisecond, 0.5))
+	defer cache.StopJanitor()
+
+	clock.Advance(ttl)
+	assert.Eventually(t, func() bool {
+		return cache.NumEntries() == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestPruneExpired(t *testing.T) {
+
+	ttl := time.Minute
+	cache := New(Capacity, Factor, ttl, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	clock := newFakeClock(cache)
+	var evicted []string
+	cache.SetOnEvict(func(key string, value interface{}, reason EvictReason) {
+		assert.Equal(t, EvictExpired, reason)
+		evicted = append(evicted, key)
+	})
+
+	// live and expired entries interleaved in the lru list
+	for i := 0; i < 10; i++ {
+		if i%2 == 0 {
+			_, err := cache.InsertOrUpdateWithTTL(i, i, time.Hour)
+			assert.NoError(t, err)
+		} else {
+			_, err := cache.InsertOrUpdate(i, i)
+			assert.NoError(t, err)
+		}
+	}
+	clock.Advance(ttl)
+
+	assert.Equal(t, 5, cache.PruneExpired())
+	assert.ElementsMatch(t, []string{"1", "3", "5", "7", "9"}, evicted)
+	assert.Equal(t, 5, cache.NumEntries())
+	assert.Equal(t, []string{"8", "6", "4", "2", "0"}, cacheKeys(cache))
+	assert.NoError(t, cache.VerifyCounts())
+	for i := 0; i < 10; i += 2 {
+		value, err := cache.Read(i)
+		assert.NoError(t, err)
+		assert.Equal(t, i, value)
+	}
+
+	assert.Equal(t, 0, cache.PruneExpired())
+}
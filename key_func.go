@@ -0,0 +1,61 @@
+package simple_cache
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// DefaultKeyFunc Stringify the keys of the common comparable types, so the constructors can be given a
+// nil toMapKey. Strings are returned as they are, integers in base 10, floats in the shortest
+// representation that parses back to the same value, booleans as "true" or "false", and any other
+// type implementing fmt.Stringer through its String method. The named types of those basic kinds
+// without a String method are stringified as their kind. Return error for any other type, instead
+// of a %v formatting, which is not deterministic for maps and pointers.
+//
+// Keys of different types can be stringified to the same string, for instance 1 and "1", so a cache
+// should use a single key type
+func DefaultKeyFunc(key interface{}) (string, error) {
+
+	switch k := key.(type) {
+	case string:
+		return k, nil
+	case int:
+		return strconv.Itoa(k), nil
+	case int64:
+		return strconv.FormatInt(k, 10), nil
+	case int32:
+		return strconv.FormatInt(int64(k), 10), nil
+	case uint64:
+		return strconv.FormatUint(k, 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(k), 10), nil
+	case float64:
+		return strconv.FormatFloat(k, 'g', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(k), nil
+	case fmt.Stringer:
+		return k.String(), nil
+	case nil:
+		return "", errors.New("nil key is not supported by DefaultKeyFunc")
+	}
+
+	v := reflect.ValueOf(key)
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	}
+
+	return "", fmt.Errorf("key type %T is not supported by DefaultKeyFunc", key)
+}
@@ -0,0 +1,72 @@
+package simple_cache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type keyID string
+
+type point struct{ x, y int }
+
+func (p point) String() string {
+	return strconv.Itoa(p.x) + "," + strconv.Itoa(p.y)
+}
+
+func TestDefaultKeyFunc(t *testing.T) {
+
+	for _, tc := range []struct {
+		key      interface{}
+		expected string
+	}{
+		{"key", "key"},
+		{keyID("id"), "id"},
+		{42, "42"},
+		{int8(-8), "-8"},
+		{int64(-1 << 40), "-1099511627776"},
+		{uint16(16), "16"},
+		{uint64(1 << 63), "9223372036854775808"},
+		{1.5, "1.5"},
+		{float32(0.1), "0.1"},
+		{true, "true"},
+		{point{1, 2}, "1,2"},
+		{time.Second, "1s"}, // a Stringer
+	} {
+		stringKey, err := DefaultKeyFunc(tc.key)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, stringKey)
+	}
+
+	for _, key := range []interface{}{nil, []int{1}, map[string]int{"a": 1}, &ValueType{}, struct{}{}} {
+		_, err := DefaultKeyFunc(key)
+		assert.Error(t, err, "%T", key)
+	}
+}
+
+func TestNilKeyFunc(t *testing.T) {
+
+	cache := New(Capacity, Factor, time.Hour, nil)
+	_, err := cache.InsertOrUpdate(7, "seven")
+	assert.NoError(t, err)
+	value, err := cache.Read(7)
+	assert.NoError(t, err)
+	assert.Equal(t, "seven", value)
+	assert.Equal(t, []string{"7"}, cacheKeys(cache))
+	_, err = cache.InsertOrUpdate([]int{7}, "unsupported")
+	assert.Error(t, err)
+
+	sharded := NewSharded(4, Capacity, Factor, time.Hour, nil)
+	_, err = sharded.InsertOrUpdate("key", 1)
+	assert.NoError(t, err)
+	value, err = sharded.Read("key")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+
+	typed := NewTyped[keyID, int](Capacity, Factor, time.Hour, nil)
+	assert.NoError(t, typed.InsertOrUpdate("id", 1))
+	n, err := typed.Read("id")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+}
@@ -0,0 +1,87 @@
+package simple_cache
+
+import "sync/atomic"
+
+// SetValueEquals Set the function used by InsertOrUpdate to detect that the new value is equal to
+// the stored one. Such an update is a no-op: the value is neither serialized nor written and, unless
+// SetRefreshOnNoopUpdate(true) was called, the ttl of the entry is not reset. A nil equals disables
+// the detection (default).
+//
+// equals receives the current value (decompressed in compression mode) and the new one. It is called
+// without holding the internal lock
+func (cache *SimpleCache) SetValueEquals(equals func(a, b interface{}) bool) {
+
+	cache.writeLock()
+	defer cache.lock.Unlock()
+
+	cache.valueEquals = equals
+}
+
+// SetRefreshOnNoopUpdate Set whether a no-op update detected through SetValueEquals resets the ttl
+// of the entry. Default is false
+func (cache *SimpleCache) SetRefreshOnNoopUpdate(refresh bool) {
+
+	cache.writeLock()
+	defer cache.lock.Unlock()
+
+	cache.refreshOnNoopUpdate = refresh
+}
+
+// NoopUpdates Return the number of updates skipped because the value had not changed
+func (cache *SimpleCache) NoopUpdates() int {
+	return int(atomic.LoadInt64(&cache.noopUpdates))
+}
+
+// Tell whether the no-op detection is enabled
+func (cache *SimpleCache) hasValueEquals() bool {
+
+	cache.lock.RLock()
+	defer cache.lock.RUnlock()
+
+	return cache.valueEquals != nil
+}
+
+// Check whether writing value on stringKey would be a no-op and, if so, account it. Must be called
+// without the lock. Return the stored value and true if the update was skipped
+func (cache *SimpleCache) tryNoopUpdate(stringKey string, value interface{}) (interface{}, bool) {
+
+	cache.writeLock()
+	equals := cache.valueEquals
+	entry := cache.table[stringKey]
+	if equals == nil || entry == nil || entry.state != BUSY || entry.hasExpired(cache.now()) {
+		cache.lock.Unlock()
+		return nil, false
+	}
+	stored, raw, version := entry.value, entry.raw, entry.version
+	cache.lock.Unlock()
+
+	current := stored
+	if cache.toCompress && !raw {
+		var err error
+		current, err = cache.decodeValue(stored.([]byte))
+		if err != nil {
+			return nil, false // let the regular path overwrite it
+		}
+	}
+
+	if !equals(current, value) {
+		return nil, false
+	}
+
+	defer cache.lock.Unlock()
+	cache.writeLock()
+
+	if cache.table[stringKey] != entry || entry.version != version || entry.state != BUSY {
+		return nil, false // written by someone else meanwhile
+	}
+
+	atomic.AddInt64(&cache.hitCount, 1)
+	atomic.AddInt64(&cache.noopUpdates, 1)
+	if cache.refreshOnNoopUpdate {
+		currTime := cache.now()
+		entry.timestamp = currTime
+		entry.setExpirationTime(currTime.Add(entry.ttl))
+	}
+
+	return entry.value, true
+}
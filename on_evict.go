@@ -0,0 +1,72 @@
+package simple_cache
+
+import "sync/atomic"
+
+// EvictReason tells an OnEvict callback why an entry left the cache
+type EvictReason int
+
+const (
+	EvictCapacity EvictReason = iota // evicted to make room, by an insertion or by EvictTo
+	EvictDelete                      // removed by Delete or Clean
+	EvictExpired                     // its ttl expired and the entry was reclaimed
+)
+
+func (reason EvictReason) String() string {
+	switch reason {
+	case EvictCapacity:
+		return "capacity"
+	case EvictDelete:
+		return "delete"
+	case EvictExpired:
+		return "expired"
+	}
+	return "unknown"
+}
+
+// SetOnEvict Set fn to be called with each entry that leaves the cache, so that the resources held
+// by its value can be released. An expired entry is notified when it is reclaimed, not when it
+// expires, and it is not notified at all if InsertOrUpdate overwrites it first, as happens with any
+// value replaced by InsertOrUpdate. Pinned keys are notified when Delete or Clean removes them.
+// Entries moved to the disk tier are not notified. The cache returned by Rotate keeps fn, but the one
+// returned by Clone does not, since it may share the values. In compression mode fn receives the
+// decoded value, or nil if it cannot be decoded. A nil fn disables the callback.
+//
+// Locking contract: fn is called after the internal lock has been released, by the goroutine whose
+// call caused the eviction and before that call returns, so fn may call back into the cache.
+// Several goroutines may run fn concurrently
+func (cache *SimpleCache) SetOnEvict(fn func(key string, value interface{}, reason EvictReason)) {
+
+	cache.writeLock()
+	defer cache.lock.Unlock()
+
+	cache.onEvict = fn
+}
+
+// Count the eviction of entry and queue the OnEvict callback, if any; mutex must be taken. Must be
+// called before the entry is reused
+func (cache *SimpleCache) evicted(entry *SimpleCacheEntry, reason EvictReason) {
+
+	if entry.state != BUSY {
+		return
+	}
+	switch reason {
+	case EvictCapacity:
+		atomic.AddInt64(&cache.evictionCount, 1)
+	case EvictExpired:
+		cache.countExpired(entry)
+	}
+	cache.queueEvicted(entry.stored(), reason)
+}
+
+// Queue the OnEvict callback, if any, for stored; mutex must be taken
+func (cache *SimpleCache) queueEvicted(stored storedValue, reason EvictReason) {
+
+	fn := cache.onEvict
+	if fn == nil {
+		return
+	}
+	cache.pendingCallbacks = append(cache.pendingCallbacks, func() {
+		value, _ := cache.decodeStored(stored) // a failure is counted by decodeStored
+		fn(stored.key, value, reason)
+	})
+}
@@ -0,0 +1,93 @@
+package simple_cache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type evictedEntry struct {
+	key    string
+	value  interface{}
+	reason EvictReason
+}
+
+func TestOnEvict(t *testing.T) {
+
+	ttl := 50 * time.Millisecond
+	cache := New(3, Factor, ttl, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	clock := newFakeClock(cache)
+
+	var evicted []evictedEntry
+	cache.SetOnEvict(func(key string, value interface{}, reason EvictReason) {
+		evicted = append(evicted, evictedEntry{key, value, reason})
+		cache.NumEntries() // the lock is not held
+		_, _ = cache.Peek(0)
+	})
+
+	for i := 0; i < 3; i++ {
+		_, err := cache.InsertOrUpdate(i, i*10)
+		assert.NoError(t, err)
+	}
+
+	// a live lru entry is not evicted by an insertion
+	_, err := cache.InsertOrUpdate(3, 30)
+	assert.ErrorIs(t, err, ErrCacheFull)
+	assert.Empty(t, evicted)
+
+	deleted, err := cache.Delete(1)
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+	assert.Equal(t, []evictedEntry{{"1", 10, EvictDelete}}, evicted)
+
+	clock.Advance(ttl)
+	evicted = nil
+	_, err = cache.InsertOrUpdate(3, 30)
+	assert.NoError(t, err)
+	_, err = cache.InsertOrUpdate(4, 40)
+	assert.NoError(t, err)
+	assert.Equal(t, []evictedEntry{{"0", 0, EvictExpired}}, evicted)
+
+	evicted = nil
+	assert.Equal(t, 3, cache.EvictTo(0))
+	assert.Equal(t, []evictedEntry{{"2", 20, EvictExpired}, {"3", 30, EvictCapacity}, {"4", 40, EvictCapacity}}, evicted)
+
+	evicted = nil
+	_, err = cache.InsertOrUpdate(5, 50)
+	assert.NoError(t, err)
+	assert.NoError(t, cache.Clean())
+	assert.Equal(t, []evictedEntry{{"5", 50, EvictDelete}}, evicted)
+
+	// the entries left by Clean are not notified again when reused
+	evicted = nil
+	for i := 0; i < 3; i++ {
+		_, err = cache.InsertOrUpdate(i, i)
+		assert.NoError(t, err)
+	}
+	assert.Empty(t, evicted)
+}
+
+func TestOnEvictDecodesValues(t *testing.T) {
+
+	cache := newValueTypeCache(Capacity, time.Hour)
+
+	var evicted []evictedEntry
+	cache.SetOnEvict(func(key string, value interface{}, reason EvictReason) {
+		evicted = append(evicted, evictedEntry{key, value, reason})
+	})
+
+	_, err := cache.InsertOrUpdate(1, &ValueType{Num: 1})
+	assert.NoError(t, err)
+	_, err = cache.InsertOrUpdate(2, &ValueType{Num: 2})
+	assert.NoError(t, err)
+	assert.NoError(t, cache.PinImmutable(2))
+
+	assert.NoError(t, cache.Clean())
+	assert.ElementsMatch(t, []evictedEntry{
+		{"1", &ValueType{Num: 1}, EvictDelete},
+		{"2", &ValueType{Num: 2}, EvictDelete},
+	}, evicted)
+}
@@ -0,0 +1,76 @@
+package simple_cache
+
+import (
+	"fmt"
+	"time"
+)
+
+// Capacity factor of NewWithOptions if WithCapFactor is not given
+const defaultCapFactor = 0.5
+
+// Option configures a cache created by NewWithOptions
+type Option func(*cacheOptions)
+
+type cacheOptions struct {
+	capFactor        float64
+	ttl              time.Duration
+	toMapKey         func(key interface{}) (string, error)
+	expirationPolicy ExpirationPolicy
+}
+
+// WithCapFactor Set the capacity factor, which must be in [0.1, 3]. Default is 0.5
+func WithCapFactor(capFactor float64) Option {
+	return func(o *cacheOptions) {
+		o.capFactor = capFactor
+	}
+}
+
+// WithTTL Set the ttl of the entries, which must be positive. It is required
+func WithTTL(ttl time.Duration) Option {
+	return func(o *cacheOptions) {
+		o.ttl = ttl
+	}
+}
+
+// WithKeyFunc Set the function that stringifies the keys. Default is DefaultKeyFunc
+func WithKeyFunc(toMapKey func(key interface{}) (string, error)) Option {
+	return func(o *cacheOptions) {
+		o.toMapKey = toMapKey
+	}
+}
+
+// WithExpirationPolicy Set whether Read extends the lifetime of the entries. See SetExpirationPolicy
+func WithExpirationPolicy(policy ExpirationPolicy) Option {
+	return func(o *cacheOptions) {
+		o.expirationPolicy = policy
+	}
+}
+
+// NewWithOptions Like New, but the configuration is given by options and it is validated: instead of
+// panicking, it returns an error if capacity is not positive, the capacity factor is out of range or
+// the ttl is not positive
+func NewWithOptions(capacity int, opts ...Option) (*SimpleCache, error) {
+
+	o := cacheOptions{capFactor: defaultCapFactor}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if capacity <= 0 {
+		return nil, fmt.Errorf("invalid capacity %d. It should be positive", capacity)
+	}
+	if err := checkCapFactor(o.capFactor); err != nil {
+		return nil, err
+	}
+	if o.ttl <= 0 {
+		return nil, fmt.Errorf("invalid ttl %s. It should be positive and set with WithTTL", o.ttl)
+	}
+	if o.expirationPolicy != SlidingExpiration && o.expirationPolicy != AbsoluteExpiration {
+		return nil, fmt.Errorf("invalid expiration policy %d", o.expirationPolicy)
+	}
+
+	cache := newCache(capacity, o.capFactor, o.ttl, o.toMapKey, 0)
+	cache.expirationPolicy = o.expirationPolicy
+
+	return cache, nil
+}
@@ -0,0 +1,55 @@
+package simple_cache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNewWithOptions(t *testing.T) {
+
+	toMapKey := func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	}
+
+	cache, err := NewWithOptions(Capacity, WithTTL(time.Hour), WithKeyFunc(toMapKey),
+		WithCapFactor(Factor), WithExpirationPolicy(AbsoluteExpiration))
+	assert.NoError(t, err)
+	assert.Equal(t, Capacity, cache.Capacity())
+	assert.Equal(t, time.Hour, cache.Ttl())
+	assert.Equal(t, AbsoluteExpiration, cache.expirationPolicy)
+	_, err = cache.InsertOrUpdate(1, 1)
+	assert.NoError(t, err)
+	value, err := cache.Read(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+
+	cache, err = NewWithOptions(Capacity, WithTTL(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, SlidingExpiration, cache.expirationPolicy)
+	_, err = cache.InsertOrUpdate("key", 1) // with DefaultKeyFunc
+	assert.NoError(t, err)
+
+	for _, tc := range []struct {
+		name     string
+		capacity int
+		opts     []Option
+		msg      string
+	}{
+		{"capacity", 0, []Option{WithTTL(time.Hour), WithKeyFunc(toMapKey)}, "invalid capacity 0"},
+		{"capFactor", Capacity, []Option{WithTTL(time.Hour), WithKeyFunc(toMapKey), WithCapFactor(5)},
+			"invalid capFactor"},
+		{"ttl", Capacity, []Option{WithKeyFunc(toMapKey)}, "invalid ttl 0s"},
+		{"policy", Capacity, []Option{WithTTL(time.Hour), WithKeyFunc(toMapKey), WithExpirationPolicy(7)},
+			"invalid expiration policy 7"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cache, err := NewWithOptions(tc.capacity, tc.opts...)
+			assert.Nil(t, cache)
+			assert.ErrorContains(t, err, tc.msg)
+		})
+	}
+
+	assert.Panics(t, func() { New(Capacity, 5, time.Hour, toMapKey) })
+}
@@ -0,0 +1,103 @@
+package simple_cache
+
+import (
+	"errors"
+	"sort"
+)
+
+// SetOrderKey Enable a secondary index that keeps the entries sorted by orderKey(key), so that
+// they can be retrieved by ranges with RangeQuery. It is intended for naturally ordered keys, such
+// as timestamps or sequence numbers. orderKey receives the original key, it is called with the
+// internal lock taken and it must always return the same value for the same key.
+//
+// The index must be set up before inserting; it returns error if the cache is not empty.
+func (cache *SimpleCache) SetOrderKey(orderKey func(key interface{}) int64) error {
+
+	cache.writeLock()
+	defer cache.lock.Unlock()
+
+	if len(cache.table) > 0 {
+		return errors.New("the ordered index can only be set on an empty cache")
+	}
+
+	cache.orderKey = orderKey
+	cache.orderIndex = nil
+
+	return nil
+}
+
+// RangeQuery Return the values of the live entries whose order key falls in [lo, hi], sorted by
+// order key. It neither refreshes the entries nor changes counters. Entries whose value cannot be
+// decoded in compression mode are skipped. Return nil if no ordered index has been set.
+func (cache *SimpleCache) RangeQuery(lo, hi int64) []interface{} {
+
+	var found []storedValue
+	indexed := func() bool {
+		defer cache.lock.RUnlock()
+		cache.lock.RLock()
+
+		if cache.orderKey == nil {
+			return false
+		}
+
+		currTime := cache.now()
+		for i := cache.searchOrderIndex(lo); i < len(cache.orderIndex); i++ {
+			entry := cache.orderIndex[i]
+			if entry.order > hi {
+				break
+			}
+			if entry.state == BUSY && !entry.hasExpired(currTime) {
+				found = append(found, entry.stored())
+			}
+		}
+		return true
+	}()
+	if !indexed {
+		return nil
+	}
+
+	ret := make([]interface{}, 0, len(found))
+	for _, stored := range found {
+		value, err := cache.decodeStored(stored)
+		if err != nil {
+			continue
+		}
+		ret = append(ret, value)
+	}
+
+	return ret
+}
+
+// Return the position of the first entry whose order is >= order; mutex must be taken
+func (cache *SimpleCache) searchOrderIndex(order int64) int {
+	return sort.Search(len(cache.orderIndex), func(i int) bool {
+		return cache.orderIndex[i].order >= order
+	})
+}
+
+// mutex must be taken
+func (cache *SimpleCache) insertIntoOrderIndex(entry *SimpleCacheEntry) {
+	pos := sort.Search(len(cache.orderIndex), func(i int) bool {
+		return cache.orderIndex[i].order > entry.order // after the entries with the same order
+	})
+	cache.orderIndex = append(cache.orderIndex, nil)
+	copy(cache.orderIndex[pos+1:], cache.orderIndex[pos:])
+	cache.orderIndex[pos] = entry
+}
+
+// mutex must be taken. Does nothing if entry is not indexed
+func (cache *SimpleCache) removeFromOrderIndex(entry *SimpleCacheEntry) {
+	if cache.orderKey == nil {
+		return
+	}
+	for i := cache.searchOrderIndex(entry.order); i < len(cache.orderIndex); i++ {
+		curr := cache.orderIndex[i]
+		if curr.order != entry.order {
+			return
+		}
+		if curr == entry {
+			cache.orderIndex = append(cache.orderIndex[:i], cache.orderIndex[i+1:]...)
+			return
+		}
+	}
+}
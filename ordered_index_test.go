@@ -0,0 +1,52 @@
+package simple_cache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRangeQuery(t *testing.T) {
+
+	ttl := 100 * time.Millisecond
+	cache := New(10, Factor, ttl, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	clock := newFakeClock(cache)
+	assert.Nil(t, cache.RangeQuery(0, 100))
+
+	assert.NoError(t, cache.SetOrderKey(func(key interface{}) int64 {
+		return int64(key.(int))
+	}))
+
+	// Inserted out of order
+	for _, i := range []int{5, 1, 9, 3, 7} {
+		_, err := cache.InsertOrUpdate(i, i*10)
+		assert.NoError(t, err)
+	}
+	assert.Error(t, cache.SetOrderKey(nil))
+
+	assert.Equal(t, []interface{}{10, 30, 50}, cache.RangeQuery(0, 5))
+	assert.Equal(t, []interface{}{30, 50, 70}, cache.RangeQuery(3, 7))
+	assert.Equal(t, []interface{}{90}, cache.RangeQuery(9, 9))
+	assert.Empty(t, cache.RangeQuery(10, 20))
+
+	// Updating keeps the index consistent
+	_, err := cache.InsertOrUpdate(3, 33)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{33, 50}, cache.RangeQuery(2, 6))
+
+	clock.Advance(ttl)
+	_, err = cache.InsertOrUpdate(7, 77)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{77}, cache.RangeQuery(0, 100))
+
+	// Fill the cache so that expired entries are evicted and removed from the index
+	for i := 20; i < 29; i++ {
+		_, err := cache.InsertOrUpdate(i, i)
+		assert.NoError(t, err)
+	}
+	assert.Len(t, cache.orderIndex, 10)
+	assert.Equal(t, []interface{}{77, 20, 21}, cache.RangeQuery(0, 21))
+}
@@ -0,0 +1,38 @@
+package simple_cache
+
+import "errors"
+
+// AttachShadow Mirror every InsertOrUpdate and Read on cache to shadow. The results of the
+// shadow are never served, they are only recorded in its counters, so that comparing the
+// HitCount and MissCount of both caches tells how an alternate configuration (capacity, ttl,
+// policies) would have performed with the same traffic.
+//
+// The shadow receives the operation before the cache itself and without any lock taken. Errors
+// from the shadow are ignored. Passing nil detaches the current shadow.
+//
+// Return error if attaching shadow would create a cycle of shadows
+func (cache *SimpleCache) AttachShadow(shadow *SimpleCache) error {
+
+	for s := shadow; s != nil; s = s.getShadow() {
+		if s == cache {
+			return errors.New("attaching the shadow would create a cycle")
+		}
+	}
+
+	cache.shadow.Store(&shadowHolder{shadow: shadow})
+
+	return nil
+}
+
+// atomic.Value does not accept nil, so the shadow is wrapped
+type shadowHolder struct {
+	shadow *SimpleCache
+}
+
+func (cache *SimpleCache) getShadow() *SimpleCache {
+	holder, _ := cache.shadow.Load().(*shadowHolder)
+	if holder == nil {
+		return nil
+	}
+	return holder.shadow
+}
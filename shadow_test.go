@@ -0,0 +1,44 @@
+package simple_cache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestAttachShadow(t *testing.T) {
+
+	toMapKey := func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	}
+	cache := New(10, Factor, time.Hour, toMapKey)
+	shadow := New(5, Factor, time.Hour, toMapKey)
+
+	assert.NoError(t, cache.AttachShadow(shadow))
+	assert.Error(t, shadow.AttachShadow(cache))
+	assert.Error(t, cache.AttachShadow(cache))
+
+	for i := 0; i < 10; i++ {
+		_, err := cache.InsertOrUpdate(i, i)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 10, cache.NumEntries())
+	assert.Equal(t, 5, shadow.NumEntries()) // the last 5 insertions failed in the shadow
+
+	for i := 0; i < 10; i++ {
+		value, err := cache.Read(i)
+		assert.NoError(t, err)
+		assert.Equal(t, i, value)
+	}
+
+	cacheHits, shadowHits := cache.HitCount(), shadow.HitCount()
+	_, _ = cache.Read(9)
+	_, _ = cache.Read(0)
+	assert.Equal(t, cacheHits+2, cache.HitCount())
+	assert.Equal(t, shadowHits+1, shadow.HitCount())
+
+	assert.NoError(t, cache.AttachShadow(nil))
+	_, _ = cache.Read(1)
+	assert.Equal(t, shadowHits+1, shadow.HitCount())
+}
@@ -0,0 +1,97 @@
+package simple_cache
+
+import "time"
+
+// ShardedCache Set of independent SimpleCache instances, each one with its own lock, among which the
+// keys are distributed by the hash of their stringficated key. Concurrent operations on keys of
+// different shards do not contend.
+//
+// The lru eviction is per shard, not global: an insertion can fail with ErrCacheFull because its
+// shard is full of live entries while other shards have room, and the entry evicted is the lru one
+// of its shard, which is not necessarily the lru one of the whole cache
+type ShardedCache struct {
+	shards   []*SimpleCache
+	toMapKey func(key interface{}) (string, error)
+}
+
+// NewSharded Create a ShardedCache with shards caches of capacityPerShard entries each, so its total
+// capacity is shards * capacityPerShard. The other parameters are the same as New. shards < 1 is
+// taken as 1, and a nil toMapKey as DefaultKeyFunc
+func NewSharded(shards int, capacityPerShard int, capFactor float64, ttl time.Duration,
+	toMapKey func(key interface{}) (string, error)) *ShardedCache {
+
+	if shards < 1 {
+		shards = 1
+	}
+	if toMapKey == nil {
+		toMapKey = DefaultKeyFunc
+	}
+	ret := &ShardedCache{shards: make([]*SimpleCache, shards), toMapKey: toMapKey}
+	for i := range ret.shards {
+		ret.shards[i] = New(capacityPerShard, capFactor, ttl, toMapKey)
+	}
+	return ret
+}
+
+// Return the shard of key and its stringficated key, so that the shard does not compute it again
+func (sharded *ShardedCache) shard(key interface{}) (*SimpleCache, string, error) {
+	stringKey, err := sharded.toMapKey(key)
+	if err != nil {
+		return nil, "", err
+	}
+	return sharded.shards[mix64(fnv64a(stringKey))%uint64(len(sharded.shards))], stringKey, nil
+}
+
+// InsertOrUpdate See SimpleCache.InsertOrUpdate
+func (sharded *ShardedCache) InsertOrUpdate(key interface{}, value interface{}) (interface{}, error) {
+	shard, stringKey, err := sharded.shard(key)
+	if err != nil {
+		return nil, err
+	}
+	return shard.insertOrUpdateKey(key, stringKey, value, insertOptions{ttl: shard.ttl})
+}
+
+// Read See SimpleCache.Read
+func (sharded *ShardedCache) Read(key interface{}) (interface{}, error) {
+	shard, stringKey, err := sharded.shard(key)
+	if err != nil {
+		return nil, err
+	}
+	return shard.readKey(key, stringKey)
+}
+
+// Delete See SimpleCache.Delete
+func (sharded *ShardedCache) Delete(key interface{}) (bool, error) {
+	shard, stringKey, err := sharded.shard(key)
+	if err != nil {
+		return false, err
+	}
+	return shard.deleteKey(stringKey), nil
+}
+
+// NumEntries Return the sum of NumEntries of the shards
+func (sharded *ShardedCache) NumEntries() int {
+	ret := 0
+	for _, shard := range sharded.shards {
+		ret += shard.NumEntries()
+	}
+	return ret
+}
+
+// HitCount Return the sum of HitCount of the shards
+func (sharded *ShardedCache) HitCount() int {
+	ret := 0
+	for _, shard := range sharded.shards {
+		ret += shard.HitCount()
+	}
+	return ret
+}
+
+// MissCount Return the sum of MissCount of the shards
+func (sharded *ShardedCache) MissCount() int {
+	ret := 0
+	for _, shard := range sharded.shards {
+		ret += shard.MissCount()
+	}
+	return ret
+}
@@ -0,0 +1,97 @@
+package simple_cache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShardedCache(t *testing.T) {
+
+	const shards = 4
+	cache := NewSharded(shards, Capacity, Factor, time.Hour, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+
+	for i := 0; i < Capacity; i++ {
+		_, err := cache.InsertOrUpdate(i, i)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, Capacity, cache.NumEntries())
+	assert.Equal(t, Capacity, cache.MissCount())
+
+	for i := 0; i < Capacity; i++ {
+		value, err := cache.Read(i)
+		assert.NoError(t, err)
+		assert.Equal(t, i, value)
+	}
+	assert.Equal(t, Capacity, cache.HitCount())
+
+	// the keys are spread among all the shards
+	for _, shard := range cache.shards {
+		assert.NotZero(t, shard.NumEntries())
+	}
+
+	deleted, err := cache.Delete(3)
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+	_, err = cache.Read(3)
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	assert.Equal(t, Capacity-1, cache.NumEntries())
+}
+
+func BenchmarkInsertOrUpdateParallel(b *testing.B) {
+
+	const numKeys = 1024
+	toMapKey := func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	}
+
+	caches := []struct {
+		name  string
+		cache interface {
+			InsertOrUpdate(key interface{}, value interface{}) (interface{}, error)
+		}
+	}{
+		{"single", New(numKeys, Factor, time.Hour, toMapKey)},
+		{"sharded", NewSharded(16, numKeys/16*2, Factor, time.Hour, toMapKey)}, // room for an uneven spread
+	}
+
+	for _, c := range caches {
+		b.Run(c.name, func(b *testing.B) {
+			var seed int64
+			b.RunParallel(func(pb *testing.PB) {
+				// each goroutine writes its own slice of keys
+				i := int(atomic.AddInt64(&seed, 1)) * 64
+				for pb.Next() {
+					if _, err := c.cache.InsertOrUpdate(i%numKeys, i); err != nil {
+						b.Fatal(err)
+					}
+					i++
+					if i%64 == 0 {
+						i -= 64
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestShardedCacheStringifiesOnce(t *testing.T) {
+
+	var calls int32
+	cache := NewSharded(4, Capacity, Factor, time.Hour, func(key interface{}) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		return strconv.Itoa(key.(int)), nil
+	})
+
+	_, err := cache.InsertOrUpdate(1, 1)
+	assert.NoError(t, err)
+	_, err = cache.Read(1)
+	assert.NoError(t, err)
+	_, err = cache.Delete(1)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
@@ -9,6 +9,7 @@ import (
 	"io"
 	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -18,39 +19,159 @@ const (
 	BUSY
 )
 
+// Number of reads that can be served under the read lock before one of them has to take the write
+// lock to move the read entries to the mru
+const readBufferSize = 64
+
+// FallbackAction tells the cache what to do when the serialization of a value fails in compression mode
+type FallbackAction int
+
+const (
+	Propagate FallbackAction = iota // return the error to the caller (default)
+	StoreRaw                        // store the value uncompressed. Only meaningful on insertion
+)
+
+// ResurrectPolicy tells InsertOrUpdate what to do with a key whose entry has expired but is still in the cache
+type ResurrectPolicy int
+
+const (
+	Resurrect ResurrectPolicy = iota // overwrite the expired entry (default)
+	Reject                           // refuse the update with ErrKeyExpired
+)
+
+// ExpirationPolicy tells whether reading an entry extends its lifetime
+type ExpirationPolicy int
+
+const (
+	SlidingExpiration  ExpirationPolicy = iota // each hit extends the expiration by the ttl (default)
+	AbsoluteExpiration                         // the entry expires ttl after it was written, regardless of the reads
+)
+
+// ErrCacheFull is returned when an insertion needs to evict the lru entry, but it is still live
+var ErrCacheFull = errors.New("cache is full")
+
+// ErrKeyNotFound is wrapped by the errors returned when a key is not in the cache
+var ErrKeyNotFound = errors.New("not found")
+
+// ErrCoalesceTimeout is returned by ReadCoalesced when the load started by another reader does not finish in time
+var ErrCoalesceTimeout = errors.New("timeout waiting for a coalesced load")
+
+// ErrKeyExpired is wrapped by the errors returned when a key is found but its ttl has expired
+var ErrKeyExpired = errors.New("ttl expired")
+
 type SimpleCacheEntry struct {
-	key            string
-	value          interface{}
-	timestamp      time.Time
-	expirationTime time.Time
-	prev           *SimpleCacheEntry
-	next           *SimpleCacheEntry
-	state          int // AVAILABLE or BUSY
+	key         string
+	value       interface{}
+	timestamp   time.Time
+	expiration  int64 // unix nanoseconds. Accessed atomically because Read refreshes it under the read lock
+	prev        *SimpleCacheEntry
+	next        *SimpleCacheEntry
+	state       int    // AVAILABLE or BUSY
+	raw         bool   // value stored uncompressed because its serialization failed
+	order       int64  // key position in the ordered index, if any
+	version     uint64 // incremented each time the value is written
+	reload      func() (interface{}, error)
+	ttl         time.Duration // used to refresh the expiration
+	expiredSeen bool          // already counted by expiredCount
+	cost        int64         // accounted in totalCost. 0 unless the cache is bounded by cost
 }
 
 type SimpleCache struct {
+	// updated atomically; placed first so that they are 64-bit aligned on 32-bit platforms
+	missCount             int64
+	hitCount              int64
+	serializationFailures int64
+	evictionCount         int64 // live entries evicted from memory
+	expiredCount          int64 // entries found expired, each one counted once per expiration
+	numEntries            int64 // written under the write lock, so it can be read with any lock or atomically without it
+	capacity              int64 // idem; changed by Resize
+	totalCost             int64 // idem; sum of the costs of the BUSY entries
+	noopUpdates           int64 // updates skipped by the no-op detection
+	corruptionCount       int64 // divergences found by VerifyCounts
+
 	table map[string]*SimpleCacheEntry
 
-	missCount        int
-	hitCount         int
 	ttl              time.Duration
+	now              func() time.Time // clock used for the ttls. time.Now unless set by SetClock
 	head             SimpleCacheEntry // sentinel header node
-	lock             sync.Mutex
-	capacity         int
+	lock             sync.RWMutex     // Read takes the read lock; reorders are deferred to the read buffer
+	capFactor        float64
 	extendedCapacity int
-	numEntries       int
+	initialMapSize   int // pre-allocation of the table. 0 means extendedCapacity
 	toCompress       bool
 	toMapKey         func(key interface{}) (string, error)
 	valueToBytes     func(value interface{}) ([]byte, error)
 	bytesToValue     func([]byte) (interface{}, error)
+	codec            Codec // compression of the serialized values. Lz4Codec unless set by NewWithCodec
+	encrypt          func([]byte) ([]byte, error)
+	decrypt          func([]byte) ([]byte, error)
+
+	onSerializationError func(key string, err error) FallbackAction
+
+	resurrectPolicy  ResurrectPolicy
+	expirationPolicy ExpirationPolicy
+
+	valueEquals         func(a, b interface{}) bool
+	refreshOnNoopUpdate bool
+
+	immutables atomic.Value // map[string]*atomic.Value with the pinned keys. Copied on write under lock
+
+	orderKey   func(key interface{}) int64
+	orderIndex []*SimpleCacheEntry // sorted by order. Only used if orderKey is set
+
+	spill *spillTier // disk tier for the evicted live entries. nil if not enabled
+
+	shadow atomic.Value // *shadowHolder with the cache receiving a copy of every InsertOrUpdate and Read
+
+	reloads flightGroup // coalesces the reloads of expired entries
+	misses  missCoalescer
+
+	autoCorrectCounts bool
+
+	distinctKeys *distinctKeysTracker // nil unless enabled by EnableDistinctKeys or SetDistinctKeysAlert
+
+	fillThresholds   []*fillThreshold
+	pendingCallbacks []func() // user callbacks queued under lock. Run by unlock() once released
+	onEvict          func(key string, value interface{}, reason EvictReason)
+
+	janitor *janitor // nil if not started
+
+	maxCost int64                         // bound of totalCost. 0 if the cache is only bounded by count
+	costOf  func(value interface{}) int64 // nil if the cache is only bounded by count
+
+	// Entries read under the read lock, pending to become the mru. Each reader reserves its own slot
+	// through readPending, so they do not race with each other. Applied by writeLock()
+	readBuffer  [readBufferSize]*SimpleCacheEntry
+	readPending int32
 }
 
 func (cache *SimpleCache) MissCount() int {
-	return cache.missCount
+	return int(atomic.LoadInt64(&cache.missCount))
 }
 
 func (cache *SimpleCache) HitCount() int {
-	return cache.hitCount
+	return int(atomic.LoadInt64(&cache.hitCount))
+}
+
+// EvictionCount Return the number of live entries evicted from memory: moved to the disk tier to
+// make room, or shed by EvictTo. A high value relative to the insertions means the cache is too small
+func (cache *SimpleCache) EvictionCount() int {
+	return int(atomic.LoadInt64(&cache.evictionCount))
+}
+
+// ExpiredCount Return the number of entries found expired, by Read or when they are reclaimed. Each
+// entry counts once per expiration, however many times it is found. A high value relative to the
+// hits means the ttl is too short
+func (cache *SimpleCache) ExpiredCount() int {
+	return int(atomic.LoadInt64(&cache.expiredCount))
+}
+
+// Count the expiration of entry, unless already counted; mutex must be taken
+func (cache *SimpleCache) countExpired(entry *SimpleCacheEntry) {
+	if !entry.expiredSeen {
+		entry.expiredSeen = true
+		atomic.AddInt64(&cache.expiredCount, 1)
+	}
 }
 
 func (cache *SimpleCache) Ttl() time.Duration {
@@ -58,15 +179,72 @@ func (cache *SimpleCache) Ttl() time.Duration {
 }
 
 func (cache *SimpleCache) Capacity() int {
-	return cache.capacity
+	return int(atomic.LoadInt64(&cache.capacity))
 }
 
 func (cache *SimpleCache) ExtendedCapacity() int {
 	return cache.extendedCapacity
 }
 
+// SetInitialMapSize Set how many entries the table pre-allocates, instead of the extended capacity.
+// For a huge capacity the default pre-allocation takes a lot of memory up front, even if the cache
+// fills slowly; with a smaller n the table grows on demand. It does not change the capacity nor the
+// extended capacity. The current table is replaced, so the cache must be empty; the size is also
+// applied to the fresh table created by Rotate. n <= 0 restores the default
+func (cache *SimpleCache) SetInitialMapSize(n int) error {
+
+	cache.writeLock()
+	defer cache.lock.Unlock()
+
+	if len(cache.table) > 0 {
+		return errors.New("the initial map size can only be set on an empty cache")
+	}
+
+	if n < 0 {
+		n = 0
+	}
+	cache.initialMapSize = n
+	cache.table = make(map[string]*SimpleCacheEntry, cache.tableSize())
+
+	return nil
+}
+
+// Number of entries to pre-allocate in a new table
+func (cache *SimpleCache) tableSize() int {
+	if cache.initialMapSize > 0 && cache.initialMapSize < cache.extendedCapacity {
+		return cache.initialMapSize
+	}
+	return cache.extendedCapacity
+}
+
+// NumEntries Return the number of allocated slots, that is, the entries in BUSY state. It includes
+// the expired entries that have not been reclaimed yet, but not the entries marked AVAILABLE by
+// Clean, which stay in the list until they are reused. It is the count compared against capacity
 func (cache *SimpleCache) NumEntries() int {
-	return cache.numEntries
+	return int(atomic.LoadInt64(&cache.numEntries))
+}
+
+// LiveCount Return the number of usable entries: BUSY and not expired. It is always <= NumEntries;
+// the difference is the number of expired entries waiting to be reclaimed. Pinned keys are not
+// counted by either. It walks the whole list with the internal lock taken
+func (cache *SimpleCache) LiveCount() int {
+
+	cache.lock.RLock()
+	defer cache.lock.RUnlock()
+
+	currTime := cache.now()
+	count := 0
+	for entry := cache.head.next; entry != &cache.head; entry = entry.next {
+		if entry.state == BUSY && !entry.hasExpired(currTime) {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (cache *SimpleCache) SerializationFailures() int {
+	return int(atomic.LoadInt64(&cache.serializationFailures))
 }
 
 // New Creates a new cache. Parameters are:
@@ -77,33 +255,48 @@ func (cache *SimpleCache) NumEntries() int {
 //
 // ttl: time to live of a cache entry in seconds
 //
-// toMapKey is a function in charge of transforming the request into a string
-//
+// toMapKey is a function in charge of transforming the request into a string. If nil, DefaultKeyFunc is used
 func New(capacity int, capFactor float64, ttl time.Duration,
 	toMapKey func(key interface{}) (string, error)) *SimpleCache {
 
-	if capFactor < 0.1 || capFactor > 3.0 {
-		panic(fmt.Sprintf("invalid capFactor %f. It should be in [0.1, 3]",
-			capFactor))
+	return newCache(capacity, capFactor, ttl, toMapKey, 0)
+}
+
+func newCache(capacity int, capFactor float64, ttl time.Duration,
+	toMapKey func(key interface{}) (string, error), initialMapSize int) *SimpleCache {
+
+	if err := checkCapFactor(capFactor); err != nil {
+		panic(err.Error())
+	}
+
+	if toMapKey == nil {
+		toMapKey = DefaultKeyFunc
 	}
 
 	extendedCapacity := math.Ceil((1.0 + capFactor) * float64(capacity))
 	ret := &SimpleCache{
-		missCount:        0,
-		hitCount:         0,
-		capacity:         capacity,
+		capacity:         int64(capacity),
+		capFactor:        capFactor,
 		extendedCapacity: int(extendedCapacity),
-		numEntries:       0,
+		initialMapSize:   initialMapSize,
 		ttl:              ttl,
-		table:            make(map[string]*SimpleCacheEntry, int(extendedCapacity)),
+		now:              time.Now,
 		toMapKey:         toMapKey,
 	}
+	ret.table = make(map[string]*SimpleCacheEntry, ret.tableSize())
 	ret.head.prev = &ret.head
 	ret.head.next = &ret.head
 
 	return ret
 }
 
+func checkCapFactor(capFactor float64) error {
+	if capFactor < 0.1 || capFactor > 3.0 {
+		return fmt.Errorf("invalid capFactor %f. It should be in [0.1, 3]", capFactor)
+	}
+	return nil
+}
+
 func NewWithCompression(capacity int, capFactor float64, ttl time.Duration,
 	toMapKey func(key interface{}) (string, error),
 	valueToBytes func(value interface{}) ([]byte, error),
@@ -115,13 +308,178 @@ func NewWithCompression(capacity int, capFactor float64, ttl time.Duration,
 		cache.toCompress = true
 		cache.valueToBytes = valueToBytes
 		cache.bytesToValue = bytesToValue
+		cache.codec = Lz4Codec{}
+	}
+
+	return cache
+}
+
+// NewWithEncryption Creates a cache that, on top of the compression mode, keeps the values encrypted
+// in memory: each value is serialized with valueToBytes, compressed and then encrypted with encrypt;
+// Read reverses the process with decrypt and bytesToValue. Only the encrypted bytes are kept in the
+// entries.
+//
+// Since a raw value would be kept in clear, the StoreRaw fallback of the serialization error handler
+// is not honored: errors are always propagated. Keys pinned with PinImmutable are kept decrypted
+func NewWithEncryption(capacity int, capFactor float64, ttl time.Duration,
+	toMapKey func(key interface{}) (string, error),
+	valueToBytes func(value interface{}) ([]byte, error),
+	bytesToValue func([]byte) (interface{}, error),
+	encrypt func([]byte) ([]byte, error),
+	decrypt func([]byte) ([]byte, error),
+) *SimpleCache {
+
+	cache := NewWithCompression(capacity, capFactor, ttl, toMapKey, valueToBytes, bytesToValue)
+	if cache != nil {
+		cache.encrypt = encrypt
+		cache.decrypt = decrypt
 	}
 
 	return cache
 }
 
+// SetSerializationErrorHandler Register a function called each time the serialization or deserialization
+// of a value fails in compression mode. The handler receives the stringficated key and the error.
+//
+// On insertion, returning StoreRaw makes the cache keep the value uncompressed instead of failing;
+// returning Propagate makes InsertOrUpdate return the error. On reading, the error is always propagated
+// and the returned action is ignored.
+//
+// The handler is invoked without holding the internal lock
+func (cache *SimpleCache) SetSerializationErrorHandler(handler func(key string, err error) FallbackAction) {
+
+	cache.writeLock()
+	defer cache.lock.Unlock()
+
+	cache.onSerializationError = handler
+}
+
+// Count a serialization failure and ask the handler (if any) what to do. Must be called without the lock
+func (cache *SimpleCache) serializationFailed(key string, err error) FallbackAction {
+
+	cache.lock.RLock()
+	atomic.AddInt64(&cache.serializationFailures, 1)
+	handler := cache.onSerializationError
+	cache.lock.RUnlock()
+
+	if handler == nil {
+		return Propagate
+	}
+	return handler(key, err)
+}
+
+// Serialize and compress value. Does not take the lock
+func (cache *SimpleCache) compressValue(value interface{}) ([]byte, error) {
+	buf, err := cache.valueToBytes(value)
+	if err != nil {
+		return nil, err
+	}
+	return cache.codec.Compress(buf)
+}
+
+// Serialize, compress and, if enabled, encrypt value. Does not take the lock
+func (cache *SimpleCache) encodeValue(value interface{}) ([]byte, error) {
+	buf, err := cache.compressValue(value)
+	if err != nil || cache.encrypt == nil {
+		return buf, err
+	}
+	return cache.encrypt(buf)
+}
+
+// Decrypt (if enabled), decompress and deserialize buf. Does not take the lock
+func (cache *SimpleCache) decodeValue(buf []byte) (interface{}, error) {
+	var err error
+	if cache.decrypt != nil {
+		buf, err = cache.decrypt(buf)
+		if err != nil {
+			return nil, err
+		}
+	}
+	buf, err = cache.codec.Decompress(buf)
+	if err != nil {
+		return nil, err
+	}
+	return cache.bytesToValue(buf)
+}
+
+// SetResurrectPolicy Set what InsertOrUpdate does when the key is present but expired. With Reject,
+// the key is considered gone and InsertOrUpdate returns an error wrapping ErrKeyExpired instead of
+// overwriting it; the key can be inserted again once its entry has been evicted. The values loaded
+// by the cache itself for an expired key (reloaders, GetOrCompute, ReadSmart and ReadCoalesced) are
+// written regardless of the policy. Default is Resurrect
+func (cache *SimpleCache) SetResurrectPolicy(policy ResurrectPolicy) {
+
+	cache.writeLock()
+	defer cache.lock.Unlock()
+
+	cache.resurrectPolicy = policy
+}
+
+// SetExpirationPolicy Set whether Read extends the lifetime of the entries. With AbsoluteExpiration,
+// an entry expires ttl after its last insertion or update, however often it is read. It applies to
+// the entries already in the cache too, from their next read on. Default is SlidingExpiration
+func (cache *SimpleCache) SetExpirationPolicy(policy ExpirationPolicy) {
+
+	cache.writeLock()
+	defer cache.lock.Unlock()
+
+	cache.expirationPolicy = policy
+}
+
+// Extend the expiration of entry because it was read at currTime, unless the expiration is absolute.
+// Either lock must be taken
+func (cache *SimpleCache) touch(entry *SimpleCacheEntry, currTime time.Time) {
+	if cache.expirationPolicy == SlidingExpiration {
+		entry.setExpirationTime(currTime.Add(entry.ttl))
+	}
+}
+
+// SetClock Set the function used by the cache to tell the current time, instead of time.Now, so the
+// expiration of the entries can be tested without waiting. It should be set before the cache is
+// shared between goroutines. The janitor interval is measured with the real time anyway
+func (cache *SimpleCache) SetClock(now func() time.Time) {
+
+	cache.writeLock()
+	defer cache.lock.Unlock()
+
+	cache.now = now
+}
+
+// Copy of the value of an entry, taken under lock to be decoded without it
+type storedValue struct {
+	key   string
+	value interface{}
+	raw   bool // not compressed
+}
+
+// mutex must be taken
+func (entry *SimpleCacheEntry) stored() storedValue {
+	return storedValue{key: entry.key, value: entry.value, raw: entry.raw}
+}
+
+// Return the value of stored, decoded if needed. Does not take the lock
+func (cache *SimpleCache) decodeStored(stored storedValue) (interface{}, error) {
+	if !cache.toCompress || stored.raw {
+		return stored.value, nil
+	}
+	value, err := cache.decodeValue(stored.value.([]byte))
+	if err != nil {
+		cache.serializationFailed(stored.key, err)
+		return nil, err
+	}
+	return value, nil
+}
+
+func (entry *SimpleCacheEntry) expirationTime() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&entry.expiration))
+}
+
+func (entry *SimpleCacheEntry) setExpirationTime(expirationTime time.Time) {
+	atomic.StoreInt64(&entry.expiration, expirationTime.UnixNano())
+}
+
 func (entry *SimpleCacheEntry) hasExpired(currTime time.Time) bool {
-	return entry.expirationTime.Before(currTime)
+	return atomic.LoadInt64(&entry.expiration) < currTime.UnixNano()
 }
 
 func (cache *SimpleCache) getMRU() *SimpleCacheEntry {
@@ -142,13 +500,60 @@ func (cache *SimpleCache) getLRU() *SimpleCacheEntry {
 	return nil
 }
 
+// Tell whether there is no live entry; mutex must be taken
 func (cache *SimpleCache) isEmpty() bool {
 
 	if cache.numEntries == 0 {
-		return false
+		return true
+	}
+
+	// with per-entry ttls the mru is not necessarily the last entry to expire
+	currTime := cache.now()
+	for entry := cache.head.next; entry != &cache.head; entry = entry.next {
+		if entry.state == BUSY && !entry.hasExpired(currTime) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsEmpty Return true if the cache has no live entry, that is, if LiveCount() would be 0: it holds
+// no entry at all or all of them have expired. Pinned keys are not considered. Uses internal lock
+func (cache *SimpleCache) IsEmpty() bool {
+
+	cache.lock.RLock()
+	defer cache.lock.RUnlock()
+
+	return cache.isEmpty()
+}
+
+// Take the write lock and apply the promotions of the reads served under the read lock, so the lru
+// list is in order for whoever holds the write lock
+func (cache *SimpleCache) writeLock() {
+	cache.lock.Lock()
+
+	n := int(atomic.SwapInt32(&cache.readPending, 0))
+	if n > readBufferSize {
+		n = readBufferSize
+	}
+	for i, entry := range cache.readBuffer[:n] {
+		// removals take the write lock first, but an entry could have changed in the meantime anyway
+		if entry.state == BUSY && cache.table[entry.key] == entry {
+			cache.becomeMru(entry)
+		}
+		cache.readBuffer[i] = nil
 	}
+}
 
-	return !cache.getMRU().hasExpired(time.Now())
+// Release the mutex and then run the user callbacks that were queued while it was taken
+func (cache *SimpleCache) unlock() {
+	callbacks := cache.pendingCallbacks
+	cache.pendingCallbacks = nil
+	cache.lock.Unlock()
+
+	for _, callback := range callbacks {
+		callback()
+	}
 }
 
 // Insert entry as the first item of cache (mru)
@@ -170,33 +575,84 @@ func (cache *SimpleCache) becomeMru(entry *SimpleCacheEntry) {
 	cache.insertAsMru(entry)
 }
 
-// Rewove the last item in the list (lru); mutex must be taken. The entry becomes AVAILABLE
+// Rewove the last item in the list (lru); mutex must be taken. The entry becomes AVAILABLE.
+// A live lru entry is only evicted if it can be spilled to disk. On error nothing is changed
 func (cache *SimpleCache) evictLruEntry() (*SimpleCacheEntry, error) {
 	entry := cache.head.prev // <-- LRU entry
-	if !entry.hasExpired(time.Now()) && entry.state == BUSY {
-		return nil, errors.New("cache is full")
+	if entry == &cache.head {
+		return nil, ErrCacheFull // nothing to evict
+	}
+	currTime := cache.now()
+	if !entry.hasExpired(currTime) && entry.state == BUSY {
+		if cache.spill == nil || cache.spill.store(entry, currTime) != nil {
+			return nil, ErrCacheFull
+		}
+		atomic.AddInt64(&cache.evictionCount, 1)
+	} else {
+		cache.evicted(entry, EvictExpired)
 	}
 	entry.selfDeleteFromLRUList()
 	entry.state = AVAILABLE
+	cache.setCost(entry, 0)
 	delete(cache.table, entry.key) // Key evicted
+	cache.removeFromOrderIndex(entry)
 	return entry, nil
 }
 
+// Unlink entry from the lru list and from the table; mutex must be taken
+func (cache *SimpleCache) removeEntry(entry *SimpleCacheEntry) {
+	entry.selfDeleteFromLRUList()
+	delete(cache.table, entry.key)
+	cache.removeFromOrderIndex(entry)
+	if entry.state == BUSY {
+		atomic.AddInt64(&cache.numEntries, -1)
+		cache.setCost(entry, 0) // checks the fill thresholds too
+	}
+	entry.state = AVAILABLE
+}
+
+// Remove all the BUSY entries that have expired at currTime, except keep, and the expired spilled
+// entries; mutex must be taken. Return the number of removed entries from memory
+func (cache *SimpleCache) removeExpired(currTime time.Time, keep *SimpleCacheEntry) int {
+	if cache.spill != nil {
+		cache.spill.removeExpired(currTime)
+	}
+	removed := 0
+	for entry := cache.head.next; entry != &cache.head; {
+		next := entry.next
+		if entry != keep && entry.state == BUSY && entry.hasExpired(currTime) {
+			cache.evicted(entry, EvictExpired)
+			cache.removeEntry(entry)
+			removed++
+		}
+		entry = next
+	}
+	return removed
+}
+
 func (cache *SimpleCache) allocateEntry(key string) (entry *SimpleCacheEntry, err error) {
 
-	if cache.numEntries == cache.capacity {
+	// Drop the entries left by Clean at the end of the list, so that only accounted entries are evicted
+	for lru := cache.head.prev; lru != &cache.head && lru.state == AVAILABLE; lru = cache.head.prev {
+		cache.removeEntry(lru)
+	}
+
+	// >= rather than ==, so that a count above capacity never allocates more entries
+	if cache.numEntries >= cache.capacity {
 		entry, err = cache.evictLruEntry()
 		if err != nil {
 			return nil, err
 		}
 	} else {
 		entry = new(SimpleCacheEntry)
-		cache.numEntries++
+		atomic.AddInt64(&cache.numEntries, 1)
+		cache.checkFillThresholds()
 	}
 
 	cache.insertAsMru(entry)
 	entry.key = key
 	entry.state = BUSY
+	entry.expiredSeen = false
 	cache.table[key] = entry
 
 	return entry, nil
@@ -204,123 +660,497 @@ func (cache *SimpleCache) allocateEntry(key string) (entry *SimpleCacheEntry, er
 
 // InsertOrUpdate Insert into the cache the pair key,value. If the cache already contains the
 // key, then the associated value is updated.
-// It could return error if ths stringification of the key fails or if the cache is full
+// It could return error if ths stringification of the key fails or if the cache is full. A full
+// cache, one with capacity live entries, returns ErrCacheFull and is left as it was: no entry is
+// evicted or reordered
 func (cache *SimpleCache) InsertOrUpdate(key interface{}, value interface{}) (interface{}, error) {
+	return cache.insertOrUpdate(key, value, insertOptions{ttl: cache.ttl})
+}
+
+// InsertOrUpdateWithTTL Like InsertOrUpdate, but the entry lives ttl instead of the ttl of the
+// cache. With sliding expiration, Read refreshes the entry with its own ttl too. Return error if ttl
+// is not positive
+func (cache *SimpleCache) InsertOrUpdateWithTTL(key interface{}, value interface{}, ttl time.Duration) (interface{}, error) {
+
+	if ttl <= 0 {
+		return nil, fmt.Errorf("invalid ttl %s. It should be positive", ttl)
+	}
+
+	return cache.insertOrUpdate(key, value, insertOptions{ttl: ttl})
+}
+
+// InsertOrUpdateWithReloader Like InsertOrUpdate, but the entry remembers reload. When Read finds
+// the entry expired, instead of failing it calls reload, stores the result as the new value of
+// the entry (with a fresh ttl) and returns it. Concurrent reads of the same expired key share a
+// single reload call. If reload fails, Read returns its error and the entry stays expired.
+//
+// Each entry can have its own reloader. A later InsertOrUpdate of the key removes it
+func (cache *SimpleCache) InsertOrUpdateWithReloader(key interface{}, value interface{},
+	reload func() (interface{}, error)) (interface{}, error) {
+	return cache.insertOrUpdate(key, value, insertOptions{ttl: cache.ttl, reload: reload})
+}
+
+// InsertIfRoom Insert or update key only if it does not require evicting live data: the key is
+// already in the cache, there is a free slot, or an expired entry can be reclaimed. In a cache
+// bounded by cost, the new cost, or its increase on an update, must fit in the same way. Otherwise,
+// nothing is done and it returns false without error. Intended for opportunistic, low priority
+// insertions that should back off when the cache is under pressure
+func (cache *SimpleCache) InsertIfRoom(key, value interface{}) (inserted bool, err error) {
+
+	_, err = cache.insertOrUpdate(key, value, insertOptions{ttl: cache.ttl, ifRoom: true})
+	if err == errNoRoom {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// Variations of insertOrUpdate
+type insertOptions struct {
+	ttl     time.Duration               // ttl of the entry
+	reload  func() (interface{}, error) // reloader of the entry, if any
+	ifRoom  bool                        // return errNoRoom instead of evicting (or spilling) live entries
+	refresh bool                        // write of a reloaded value, allowed on an expired entry under Reject
+}
+
+var errNoRoom = errors.New("no room without evicting live entries")
+
+func (cache *SimpleCache) insertOrUpdate(key interface{}, value interface{}, opts insertOptions) (interface{}, error) {
+
+	if shadow := cache.getShadow(); shadow != nil {
+		_, _ = shadow.InsertOrUpdate(key, value)
+	}
 
 	stringKey, err := cache.toMapKey(key)
 	if err != nil {
 		return nil, err
 	}
 
-	currTime := time.Now()
+	return cache.insertOrUpdateKey(key, stringKey, value, opts)
+}
 
-	defer cache.lock.Unlock()
-	cache.lock.Lock()
+// Like insertOrUpdate, but with the key already stringficated and without feeding the shadow
+func (cache *SimpleCache) insertOrUpdateKey(key interface{}, stringKey string, value interface{},
+	opts insertOptions) (interface{}, error) {
 
-	entry := cache.table[stringKey]
-	if entry == nil {
-		cache.missCount++
-		entry, err = cache.allocateEntry(stringKey)
-		if err != nil {
-			return nil, err
+	if cache.updateImmutable(stringKey, value) {
+		return value, nil
+	}
+
+	if opts.reload == nil && cache.hasValueEquals() {
+		if stored, ok := cache.tryNoopUpdate(stringKey, value); ok {
+			return stored, nil
 		}
 	}
 
+	// The serialization does not need the lock, so it is done before taking it. This also
+	// prevents allocating an entry for a value that cannot be stored
+	pair, err := cache.prepareValue(key, stringKey, value)
+	if err != nil {
+		return nil, err
+	}
+
+	currTime := cache.now()
+
+	defer cache.unlock()
+	cache.writeLock()
+
+	return cache.storeEntry(pair, currTime, opts)
+}
+
+// A value ready to be written under the lock
+type preparedPair struct {
+	key         interface{}
+	stringKey   string
+	storedValue interface{}
+	raw         bool  // stored uncompressed because its serialization failed
+	cost        int64 // 0 unless the cache is bounded by cost
+}
+
+// Return the value as it must be stored, whether it is stored raw because its serialization failed,
+// and its cost. Must be called without the lock
+func (cache *SimpleCache) prepareValue(key interface{}, stringKey string, value interface{}) (preparedPair, error) {
+
+	pair := preparedPair{key: key, stringKey: stringKey, storedValue: value}
 	if cache.toCompress {
-		buf, err := cache.valueToBytes(value)
+		buf, err := cache.encodeValue(value)
 		if err != nil {
+			if cache.serializationFailed(stringKey, err) != StoreRaw || cache.encrypt != nil {
+				return pair, err
+			}
+			pair.raw = true
+		} else {
+			pair.storedValue = buf
+		}
+	}
+
+	var err error
+	pair.cost, err = cache.storedCost(stringKey, pair.storedValue)
+
+	return pair, err
+}
+
+// Write the value prepared by prepareValue on the entry of its key, allocating it if needed; mutex
+// must be taken
+func (cache *SimpleCache) storeEntry(pair preparedPair, currTime time.Time, opts insertOptions) (interface{}, error) {
+
+	var err error
+	key, stringKey := pair.key, pair.stringKey
+	cache.recordKey(stringKey, currTime)
+
+	entry := cache.table[stringKey]
+	if entry != nil && entry.state == AVAILABLE {
+		cache.removeEntry(entry) // left by Clean; it is not accounted in numEntries
+		entry = nil
+	}
+	if entry == nil {
+		if opts.ifRoom && !cache.hasRoom(pair.cost) &&
+			(cache.removeExpired(currTime, nil) == 0 || !cache.hasRoom(pair.cost)) {
+			return nil, errNoRoom
+		}
+		atomic.AddInt64(&cache.missCount, 1)
+		if err = cache.reserveCost(pair.cost, nil, currTime); err != nil {
 			return nil, err
 		}
-		entry.value, err = lz4Compress(buf)
+		entry, err = cache.allocateEntry(stringKey)
+		if err == ErrCacheFull && cache.removeExpired(currTime, nil) > 0 {
+			// The lru entry is live, but there could be expired entries elsewhere in the list
+			entry, err = cache.allocateEntry(stringKey)
+		}
 		if err != nil {
 			return nil, err
 		}
+		if cache.orderKey != nil {
+			entry.order = cache.orderKey(key)
+			cache.insertIntoOrderIndex(entry)
+		}
+		if cache.spill != nil {
+			cache.spill.remove(stringKey) // the spilled value, if any, is outdated
+		}
+	} else if !opts.refresh && cache.resurrectPolicy == Reject && entry.state == BUSY && entry.hasExpired(currTime) {
+		return nil, fmt.Errorf("stringficated key %s found but %w", stringKey, ErrKeyExpired)
 	} else {
-		entry.value = value
+		delta := pair.cost - entry.cost
+		if opts.ifRoom && !cache.hasCostRoom(delta) &&
+			(cache.removeExpired(currTime, entry) == 0 || !cache.hasCostRoom(delta)) {
+			return nil, errNoRoom
+		}
+		if err = cache.reserveCost(delta, entry, currTime); err != nil {
+			return nil, err
+		}
+		atomic.AddInt64(&cache.hitCount, 1) // only an update is a hit
 	}
 
-	cache.hitCount++
+	entry.value = pair.storedValue
+	entry.raw = pair.raw
+	cache.setCost(entry, pair.cost)
+	entry.reload = opts.reload
+	entry.version++
+
+	entry.expiredSeen = false
 	entry.timestamp = currTime
-	entry.expirationTime = currTime.Add(cache.ttl)
+	entry.ttl = opts.ttl
+	entry.setExpirationTime(currTime.Add(entry.ttl))
 	return entry.value, nil
 }
 
+// Look for stringKey and refresh it. Return the stored value and whether it was stored raw. If the
+// entry has expired, the error wraps ErrKeyExpired and the reloader of the entry, if any, is returned
+func (cache *SimpleCache) readEntry(stringKey string) (interface{}, bool, func() (interface{}, error), error) {
+
+	currTime := cache.now()
+
+	// Fast path: a live entry is served under the read lock, so concurrent reads do not serialize. Its
+	// move to the mru is recorded in the read buffer and applied by the next writeLock(). Its key was
+	// already recorded when inserted
+	cache.lock.RLock()
+	if entry := cache.table[stringKey]; entry != nil && entry.state == BUSY && !entry.hasExpired(currTime) {
+		if slot := atomic.AddInt32(&cache.readPending, 1) - 1; slot < readBufferSize {
+			cache.readBuffer[slot] = entry
+			atomic.AddInt64(&cache.hitCount, 1)
+			cache.touch(entry, currTime)
+			value, raw := entry.value, entry.raw
+			cache.lock.RUnlock()
+			return value, raw, nil, nil
+		}
+	}
+	cache.lock.RUnlock()
+
+	defer cache.unlock()
+	cache.writeLock()
+
+	return cache.readLocked(stringKey, currTime)
+}
+
+// Slow path of readEntry; mutex must be taken
+func (cache *SimpleCache) readLocked(stringKey string, currTime time.Time) (interface{}, bool, func() (interface{}, error), error) {
+
+	cache.recordKey(stringKey, currTime)
+
+	entry := cache.table[stringKey]
+	if entry != nil && entry.state != BUSY {
+		entry = nil // left by Clean; its value is gone, as for Peek and Contains
+	}
+	if entry == nil && cache.spill != nil {
+		value, ok, err := cache.loadSpilled(stringKey, currTime)
+		if ok || err != nil {
+			return value, false, nil, err
+		}
+	}
+	if entry == nil {
+		atomic.AddInt64(&cache.missCount, 1)
+		return nil, false, nil, fmt.Errorf("stringficated key %s %w", stringKey, ErrKeyNotFound)
+	}
+
+	if entry.hasExpired(currTime) {
+		atomic.AddInt64(&cache.missCount, 1)
+		cache.countExpired(entry)
+		return entry.value, entry.raw, entry.reload,
+			fmt.Errorf("stringficated key %s found but %w", stringKey, ErrKeyExpired)
+	}
+
+	atomic.AddInt64(&cache.hitCount, 1)
+	cache.touch(entry, currTime)
+	cache.becomeMru(entry)
+
+	return entry.value, entry.raw, nil, nil
+}
+
 // Read Retrieves the associates value to key. Return error if the key stringification fails,
 // the key is not in the cache, or if the key has expired
 func (cache *SimpleCache) Read(key interface{}) (value interface{}, err error) {
 
-	var stringKey string
-	stringKey, err = cache.toMapKey(key)
+	if shadow := cache.getShadow(); shadow != nil {
+		_, _ = shadow.Read(key)
+	}
+
+	stringKey, err := cache.toMapKey(key)
 	if err != nil {
 		return nil, err
 	}
 
-	currTime := time.Now()
+	return cache.readKey(key, stringKey)
+}
 
-	defer cache.lock.Unlock()
-	cache.lock.Lock()
+// Like Read, but with the key already stringficated and without feeding the shadow
+func (cache *SimpleCache) readKey(key interface{}, stringKey string) (interface{}, error) {
 
-	entry := cache.table[stringKey]
-	if entry == nil {
-		cache.missCount++
-		return nil, fmt.Errorf("stringficated key %s not found", stringKey)
+	if value, ok := cache.readImmutable(stringKey); ok {
+		return value, nil
 	}
 
-	if entry.hasExpired(currTime) {
-		cache.missCount++
-		return entry.value, fmt.Errorf("stringficated key %s found but ttl expired", stringKey)
+	// stored values are never modified in place, so the decoding can be done without the lock
+	value, raw, reload, err := cache.readEntry(stringKey)
+	if reload != nil {
+		return cache.reloadEntry(key, stringKey, reload)
+	}
+	if err != nil || !cache.toCompress || raw {
+		return value, err
 	}
 
-	cache.hitCount++
-	entry.expirationTime = currTime.Add(cache.ttl)
-	cache.becomeMru(entry)
+	value, err = cache.decodeValue(value.([]byte))
+	if err != nil {
+		cache.serializationFailed(stringKey, err)
+		return nil, err
+	}
 
-	if cache.toCompress {
-		var buf []byte
-		buf, err = lz4Decompress(entry.value.([]byte))
+	return value, nil
+}
+
+// Call reload once for all the concurrent readers of stringKey and store its result
+func (cache *SimpleCache) reloadEntry(key interface{}, stringKey string,
+	reload func() (interface{}, error)) (interface{}, error) {
+
+	return cache.reloads.do(stringKey, func() (interface{}, error) {
+		value, err := reload()
 		if err != nil {
 			return nil, err
 		}
-
-		value, err = cache.bytesToValue(buf)
+		_, err = cache.insertOrUpdate(key, value, insertOptions{ttl: cache.ttl, reload: reload, refresh: true})
 		if err != nil {
 			return nil, err
 		}
-	} else {
-		value = entry.value
+		return value, nil
+	})
+}
+
+// Inspect Return the value stored for key whenever the entry exists, even if it has expired or was
+// cleaned by Clean and not yet reused, with flags telling whether it is present and whether it has
+// expired. Intended for admin and debug tools: it never mutates the cache (lru order, ttl and
+// counters are untouched). Pinned keys are present and never expire; spilled entries are not
+// inspected. Return error if the key stringification or the decoding of the value fails
+func (cache *SimpleCache) Inspect(key interface{}) (value interface{}, present bool, expired bool, err error) {
+
+	stringKey, err := cache.toMapKey(key)
+	if err != nil {
+		return nil, false, false, err
 	}
 
-	return value, nil
+	if value, ok := cache.readImmutable(stringKey); ok {
+		return value, true, false, nil
+	}
+
+	var stored storedValue
+	func() {
+		defer cache.lock.RUnlock()
+		cache.lock.RLock()
+
+		entry := cache.table[stringKey]
+		if entry == nil {
+			return
+		}
+		present = true
+		expired = entry.hasExpired(cache.now())
+		stored = entry.stored()
+	}()
+
+	if !present {
+		return nil, false, false, nil
+	}
+
+	value = stored.value
+	if cache.toCompress && !stored.raw {
+		value, err = cache.decodeValue(stored.value.([]byte))
+	}
+
+	return value, present, expired, err
+}
+
+// Peek Like Read, but without side effects: the entry is neither moved to the mru nor its ttl
+// extended, and the hit and miss counters are untouched. Pinned keys are returned; spilled entries
+// are not looked up. Return error if the key stringification fails, the key is not in the cache, or
+// if the key has expired
+func (cache *SimpleCache) Peek(key interface{}) (interface{}, error) {
+
+	stringKey, err := cache.toMapKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if value, ok := cache.readImmutable(stringKey); ok {
+		return value, nil
+	}
+
+	var stored storedValue
+	err = func() error {
+		defer cache.lock.RUnlock()
+		cache.lock.RLock()
+
+		entry := cache.table[stringKey]
+		if entry == nil || entry.state != BUSY {
+			return fmt.Errorf("stringficated key %s %w", stringKey, ErrKeyNotFound)
+		}
+		if entry.hasExpired(cache.now()) {
+			return fmt.Errorf("stringficated key %s found but %w", stringKey, ErrKeyExpired)
+		}
+		stored = entry.stored()
+		return nil
+	}()
+	if err != nil {
+		return nil, err
+	}
+
+	if !cache.toCompress || stored.raw {
+		return stored.value, nil
+	}
+	return cache.decodeValue(stored.value.([]byte))
+}
+
+// Contains Tell whether key is in the cache and has not expired. Like Peek, the entry is neither moved
+// to the mru nor its ttl extended, and the counters are untouched, but the value is not decoded.
+// Pinned keys are contained; spilled entries are not looked up. Return error only if the key
+// stringification fails
+func (cache *SimpleCache) Contains(key interface{}) (bool, error) {
+
+	stringKey, err := cache.toMapKey(key)
+	if err != nil {
+		return false, err
+	}
+
+	if _, ok := cache.readImmutable(stringKey); ok {
+		return true, nil
+	}
+
+	cache.lock.RLock()
+	defer cache.lock.RUnlock()
+
+	entry := cache.table[stringKey]
+	return entry != nil && entry.state == BUSY && !entry.hasExpired(cache.now()), nil
+}
+
+// ReadCompressed Like Read, but return a copy of the stored compressed bytes instead of the
+// value. The bytes are the output of the codec (a complete LZ4 frame by default), so they can be
+// forwarded as they are to a consumer that understands it; with encryption, they are decrypted but
+// not decompressed. Only available in compression mode. Return error too if the value was stored raw
+// because its serialization failed
+func (cache *SimpleCache) ReadCompressed(key interface{}) ([]byte, error) {
+
+	if !cache.toCompress {
+		return nil, errors.New("ReadCompressed requires a cache created with NewWithCompression")
+	}
+
+	stringKey, err := cache.toMapKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if value, ok := cache.readImmutable(stringKey); ok {
+		return cache.compressValue(value) // pinned values are kept decompressed
+	}
+
+	value, raw, _, err := cache.readEntry(stringKey)
+	if err != nil {
+		return nil, err
+	}
+	if raw {
+		return nil, fmt.Errorf("stringficated key %s is stored uncompressed", stringKey)
+	}
+
+	if cache.decrypt != nil {
+		return cache.decrypt(value.([]byte)) // decrypt must not return its input
+	}
+
+	return append([]byte(nil), value.([]byte)...), nil
 }
 
 // GetMRU Return the most recently used entry in the cache. The method do not refresh the entry
 func (cache *SimpleCache) GetMRU() (string, interface{}, error) {
 
 	defer cache.lock.Unlock()
-	cache.lock.Lock()
+	cache.writeLock()
 
 	if cache.numEntries == 0 {
 		return "", nil, errors.New("empty cache")
 	}
 
 	entry := cache.getMRU()
-	if entry.hasExpired(time.Now()) || entry.state == AVAILABLE {
+	if entry.hasExpired(cache.now()) || entry.state == AVAILABLE {
 		return entry.key, entry.value, errors.New("MRU entry has expired")
 	}
 
 	return entry.key, entry.value, nil
 }
 
-// SimpleCacheIt Iterator on cache entries. Go from MUR to LRU
+// SimpleCacheIt Iterator on cache entries. Go from MUR to LRU, or from LRU to MRU if reverse
 type SimpleCacheIt struct {
 	cachePtr *SimpleCache
 	curr     *SimpleCacheEntry
+	reverse  bool
 }
 
 func (cache *SimpleCache) NewCacheIt() *SimpleCacheIt {
+	cache.writeLock() // apply the pending reads, so that the iteration follows the lru order
+	cache.lock.Unlock()
 	return &SimpleCacheIt{cachePtr: cache, curr: cache.head.next}
 }
 
+// NewReverseCacheIt Return an iterator going from the LRU entry to the MRU one, that is, in eviction order
+func (cache *SimpleCache) NewReverseCacheIt() *SimpleCacheIt {
+	cache.writeLock() // apply the pending reads, so that the iteration follows the lru order
+	cache.lock.Unlock()
+	return &SimpleCacheIt{cachePtr: cache, curr: cache.head.prev, reverse: true}
+}
+
 func (it *SimpleCacheIt) HasCurr() bool {
 	return it.curr != &it.cachePtr.head
 }
@@ -333,31 +1163,70 @@ func (it *SimpleCacheIt) Next() *SimpleCacheEntry {
 	if !it.HasCurr() {
 		return nil
 	}
-	it.curr = it.curr.next
+	if it.reverse {
+		it.curr = it.curr.prev
+	} else {
+		it.curr = it.curr.next
+	}
 	return it.curr
 }
 
+// Range Call fn for each live entry, from mru to lru, and then for each pinned key, until fn
+// returns false. The signature matches sync.Map.Range, so the cache can be used by utilities written
+// for it. Values are decoded in compression mode; entries that cannot be decoded are skipped.
+// It neither refreshes the entries nor changes counters.
+//
+// The internal read lock is held during the whole iteration, so concurrent reads go on but fn must
+// not call any method of the cache. The promotions of the reads pending in the read buffer are not
+// applied, so the order can lag slightly behind the latest reads
+func (cache *SimpleCache) Range(fn func(key string, value interface{}) bool) {
+
+	cache.lock.RLock()
+	defer cache.lock.RUnlock()
+
+	currTime := cache.now()
+	for entry := cache.head.next; entry != &cache.head; entry = entry.next {
+		if entry.state != BUSY || entry.hasExpired(currTime) {
+			continue
+		}
+		value := entry.value
+		if cache.toCompress && !entry.raw {
+			var err error
+			value, err = cache.decodeValue(entry.value.([]byte))
+			if err != nil {
+				atomic.AddInt64(&cache.serializationFailures, 1)
+				continue
+			}
+		}
+		if !fn(entry.key, value) {
+			return
+		}
+	}
+
+	for key, holder := range cache.pinnedImmutables() {
+		if !fn(key, holder.Load().(*pinnedValue).value) {
+			return
+		}
+	}
+}
+
 type CacheState struct {
-	MissCount  int
-	HitCount   int
-	TTL        time.Duration
-	Capacity   int
-	NumEntries int
+	MissCount     int
+	HitCount      int
+	TTL           time.Duration
+	Capacity      int
+	NumEntries    int
+	EvictionCount int
+	ExpiredCount  int
 }
 
-// GetState Return a json containing the cache state. Use the internal mutex. Be careful with a deadlock
+// GetState Return a json containing the cache state. It does not take the internal lock, so it can be
+// called from anywhere, including the callbacks and while another goroutine holds the lock. Each field
+// is read atomically, but the fields are not a consistent snapshot among them when there are
+// concurrent operations
 func (cache *SimpleCache) GetState() (string, error) {
 
-	cache.lock.Lock()
-	defer cache.lock.Unlock()
-
-	state := CacheState{
-		MissCount:  cache.missCount,
-		HitCount:   cache.hitCount,
-		TTL:        cache.ttl,
-		Capacity:   cache.capacity,
-		NumEntries: cache.numEntries,
-	}
+	state := cache.stateSnapshot()
 
 	buf, err := json.MarshalIndent(&state, "", "  ")
 	if err != nil {
@@ -366,18 +1235,45 @@ func (cache *SimpleCache) GetState() (string, error) {
 	return string(buf), nil
 }
 
+// Read the counters without taking the lock
+func (cache *SimpleCache) stateSnapshot() CacheState {
+	return CacheState{
+		MissCount:     cache.MissCount(),
+		HitCount:      cache.HitCount(),
+		TTL:           cache.ttl, // set only on creation
+		Capacity:      cache.Capacity(),
+		NumEntries:    cache.NumEntries(),
+		EvictionCount: cache.EvictionCount(),
+		ExpiredCount:  cache.ExpiredCount(),
+	}
+}
+
 // helper that does not take lock
 func (cache *SimpleCache) clean() error {
 
 	// Now that we know that we can clean safely, we pass again and mark all the entries as AVAILABLE
-	for it := cache.NewCacheIt(); it.HasCurr(); it.Next() {
-		it.GetCurr().state = AVAILABLE
+	for entry := cache.head.next; entry != &cache.head; entry = entry.next {
+		cache.evicted(entry, EvictDelete)
+		entry.state = AVAILABLE
+		entry.cost = 0
+	}
+	for key, holder := range cache.pinnedImmutables() {
+		cache.queueEvicted(storedValue{key: key, value: holder.Load().(*pinnedValue).value, raw: true}, EvictDelete)
 	}
 
 	// At this point all the entries are marked as AVAILABLE ==> we reset
-	cache.numEntries = 0
-	cache.hitCount = 0
-	cache.missCount = 0
+	atomic.StoreInt64(&cache.numEntries, 0)
+	atomic.StoreInt64(&cache.totalCost, 0)
+	cache.orderIndex = nil
+	cache.checkFillThresholds()
+	cache.immutables.Store(map[string]*atomic.Value{})
+	if cache.spill != nil {
+		cache.spill.clean()
+	}
+	atomic.StoreInt64(&cache.hitCount, 0)
+	atomic.StoreInt64(&cache.missCount, 0)
+	atomic.StoreInt64(&cache.evictionCount, 0)
+	atomic.StoreInt64(&cache.expiredCount, 0)
 
 	return nil
 }
@@ -385,15 +1281,255 @@ func (cache *SimpleCache) clean() error {
 // Clean the cache. All the entries are deleted and counters reset.
 //
 // Uses internal lock
-//
 func (cache *SimpleCache) Clean() error {
 
-	cache.lock.Lock()
-	defer cache.lock.Unlock()
+	cache.writeLock()
+	defer cache.unlock()
 
 	return cache.clean()
 }
 
+// Delete Remove key from the cache, including its pinned value and its spilled copy, if any.
+// Return true if the key was in the cache, false otherwise. Return error if the key
+// stringification fails
+//
+// Uses internal lock
+func (cache *SimpleCache) Delete(key interface{}) (bool, error) {
+
+	stringKey, err := cache.toMapKey(key)
+	if err != nil {
+		return false, err
+	}
+
+	return cache.deleteKey(stringKey), nil
+}
+
+// Like Delete, but with the key already stringficated
+func (cache *SimpleCache) deleteKey(stringKey string) bool {
+
+	cache.writeLock()
+	defer cache.unlock()
+
+	deleted := false
+	if holder := cache.unpinImmutable(stringKey); holder != nil {
+		cache.queueEvicted(storedValue{key: stringKey, value: holder.Load().(*pinnedValue).value, raw: true}, EvictDelete)
+		deleted = true
+	}
+
+	if cache.spill != nil && cache.spill.entries[stringKey] != nil {
+		cache.spill.remove(stringKey)
+		deleted = true
+	}
+
+	if entry := cache.table[stringKey]; entry != nil {
+		deleted = deleted || entry.state == BUSY
+		cache.evicted(entry, EvictDelete)
+		cache.removeEntry(entry)
+	}
+
+	return deleted
+}
+
+// EvictTo Shed entries until NumEntries() <= targetCount and return how many were evicted. Expired
+// entries go first, from the lru end; then live entries are evicted from the lru end too, regardless
+// of their BUSY state. Pinned keys are never evicted. The capacity does not change, so the cache can
+// refill afterwards. Intended as a one-shot relief on memory pressure. The OnEvict callback receives
+// EvictExpired or EvictCapacity accordingly.
+//
+// Uses internal lock
+func (cache *SimpleCache) EvictTo(targetCount int) int {
+
+	cache.writeLock()
+	defer cache.unlock()
+
+	evicted := 0
+	currTime := cache.now()
+	for entry := cache.head.prev; entry != &cache.head && cache.numEntries > int64(targetCount); {
+		prev := entry.prev
+		if entry.state == BUSY && entry.hasExpired(currTime) {
+			cache.evicted(entry, EvictExpired)
+			cache.removeEntry(entry)
+			evicted++
+		}
+		entry = prev
+	}
+
+	for entry := cache.head.prev; entry != &cache.head && cache.numEntries > int64(targetCount); {
+		prev := entry.prev
+		if entry.state == BUSY {
+			evicted++
+		}
+		cache.evicted(entry, EvictCapacity)
+		cache.removeEntry(entry)
+		entry = prev
+	}
+
+	return evicted
+}
+
+// Resize Change the capacity of the cache, keeping its entries, and recompute the extended capacity
+// with the original capFactor. Growing just raises the limit. Shrinking below NumEntries() reclaims
+// the expired entries and then evicts from the lru end as an insertion would: a live lru entry is
+// only evicted if it can be moved to the disk tier. If the cache cannot shrink enough, the entries
+// evicted so far stay evicted, the capacity is not changed and the error wraps ErrCacheFull.
+//
+// Uses internal lock
+func (cache *SimpleCache) Resize(newCapacity int) error {
+
+	if newCapacity <= 0 {
+		return fmt.Errorf("invalid capacity %d. It should be positive", newCapacity)
+	}
+
+	cache.writeLock()
+	defer cache.unlock()
+
+	if cache.numEntries > int64(newCapacity) {
+		cache.removeExpired(cache.now(), nil)
+	}
+	for cache.numEntries > int64(newCapacity) {
+		if lru := cache.head.prev; lru.state == AVAILABLE {
+			cache.removeEntry(lru) // left by Clean; it is not accounted in numEntries
+			continue
+		}
+		if _, err := cache.evictLruEntry(); err != nil {
+			return fmt.Errorf("cannot shrink the cache to %d entries: %w", newCapacity, err)
+		}
+		atomic.AddInt64(&cache.numEntries, -1)
+	}
+
+	atomic.StoreInt64(&cache.capacity, int64(newCapacity))
+	cache.extendedCapacity = int(math.Ceil((1.0 + cache.capFactor) * float64(newCapacity)))
+	cache.checkFillThresholds()
+
+	return nil
+}
+
+// Return a new empty cache with the same configuration than cache; mutex must be taken
+func (cache *SimpleCache) newSibling() *SimpleCache {
+
+	ret := newCache(int(cache.capacity), cache.capFactor, cache.ttl, cache.toMapKey, cache.initialMapSize)
+	ret.toCompress = cache.toCompress
+	ret.valueToBytes = cache.valueToBytes
+	ret.bytesToValue = cache.bytesToValue
+	ret.codec = cache.codec
+	ret.encrypt = cache.encrypt
+	ret.decrypt = cache.decrypt
+	ret.onSerializationError = cache.onSerializationError
+	ret.resurrectPolicy = cache.resurrectPolicy
+	ret.expirationPolicy = cache.expirationPolicy
+	ret.valueEquals = cache.valueEquals
+	ret.refreshOnNoopUpdate = cache.refreshOnNoopUpdate
+	ret.orderKey = cache.orderKey
+	ret.now = cache.now
+	ret.maxCost = cache.maxCost
+	ret.costOf = cache.costOf
+
+	return ret
+}
+
+// Clone Return a new independent cache with the same configuration and a copy of the current
+// entries, preserving their lru order, timestamps and expiration times. Counters are copied too.
+//
+// In compression mode the stored bytes are copied, so the clone does not share any value with the
+// original. Otherwise, values are copied as they are: if they are pointers or reference types, the
+// pointed data is shared between both caches.
+//
+// Uses internal lock
+func (cache *SimpleCache) Clone() *SimpleCache {
+
+	cache.writeLock()
+	defer cache.lock.Unlock()
+
+	ret := cache.newSibling()
+	ret.missCount = atomic.LoadInt64(&cache.missCount)
+	ret.hitCount = atomic.LoadInt64(&cache.hitCount)
+	ret.evictionCount = atomic.LoadInt64(&cache.evictionCount)
+	ret.expiredCount = atomic.LoadInt64(&cache.expiredCount)
+	ret.serializationFailures = atomic.LoadInt64(&cache.serializationFailures)
+
+	// Walk from lru to mru so that each copied entry becomes the mru of the clone
+	for entry := cache.head.prev; entry != &cache.head; entry = entry.prev {
+		if entry.state != BUSY {
+			continue
+		}
+		value := entry.value
+		if buf, ok := value.([]byte); ok && cache.toCompress && !entry.raw {
+			value = append([]byte(nil), buf...)
+		}
+		copied := &SimpleCacheEntry{
+			key:        entry.key,
+			value:      value,
+			timestamp:  entry.timestamp,
+			expiration: atomic.LoadInt64(&entry.expiration),
+			state:      BUSY,
+			raw:        entry.raw,
+			order:      entry.order,
+			reload:     entry.reload,
+			ttl:        entry.ttl,
+			cost:       entry.cost,
+		}
+		ret.insertAsMru(copied)
+		ret.table[copied.key] = copied
+		ret.numEntries++
+		ret.totalCost += copied.cost
+		if ret.orderKey != nil {
+			ret.insertIntoOrderIndex(copied)
+		}
+	}
+
+	pinned := make(map[string]*atomic.Value, len(cache.pinnedImmutables()))
+	for key, holder := range cache.pinnedImmutables() {
+		copied := &atomic.Value{}
+		copied.Store(holder.Load())
+		pinned[key] = copied
+	}
+	ret.immutables.Store(pinned)
+
+	return ret
+}
+
+// Rotate Atomically replace the contents of cache with an empty table and return a new cache holding
+// the previous contents, so they can be drained at leisure. Operations on cache after Rotate see the
+// fresh, empty table. Counters are moved to the returned cache and reset on cache.
+//
+// The returned cache has the same configuration, except the pinned keys, the spill tier, the shadow
+// and the fill thresholds, which stay with cache.
+//
+// Uses internal lock
+func (cache *SimpleCache) Rotate() *SimpleCache {
+
+	cache.writeLock()
+	defer cache.unlock()
+
+	old := cache.newSibling()
+	old.table = cache.table
+	old.numEntries = atomic.SwapInt64(&cache.numEntries, 0)
+	old.totalCost = atomic.SwapInt64(&cache.totalCost, 0)
+	old.orderIndex = cache.orderIndex
+	old.missCount = atomic.SwapInt64(&cache.missCount, 0)
+	old.hitCount = atomic.SwapInt64(&cache.hitCount, 0)
+	old.evictionCount = atomic.SwapInt64(&cache.evictionCount, 0)
+	old.expiredCount = atomic.SwapInt64(&cache.expiredCount, 0)
+	old.serializationFailures = atomic.SwapInt64(&cache.serializationFailures, 0)
+	old.noopUpdates = atomic.SwapInt64(&cache.noopUpdates, 0)
+	old.onEvict = cache.onEvict // the moved entries are still to be notified
+
+	if first, last := cache.head.next, cache.head.prev; first != &cache.head {
+		old.head.next = first
+		first.prev = &old.head
+		old.head.prev = last
+		last.next = &old.head
+	}
+
+	cache.table = make(map[string]*SimpleCacheEntry, cache.tableSize())
+	cache.head.next = &cache.head
+	cache.head.prev = &cache.head
+	cache.orderIndex = nil
+	cache.checkFillThresholds()
+
+	return old
+}
+
 func lz4Compress(in []byte) ([]byte, error) {
 	r := bytes.NewReader(in)
 	w := &bytes.Buffer{}
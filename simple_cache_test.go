@@ -2,9 +2,12 @@ package simple_cache
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -21,6 +24,7 @@ func TestSimpleCache(t *testing.T) {
 	cache := New(Capacity, Factor, ttl, func(key interface{}) (string, error) {
 		return strconv.Itoa(key.(int)), nil
 	})
+	clock := newFakeClock(cache)
 
 	for i := 0; i < Capacity; i++ {
 		entry, err := cache.InsertOrUpdate(i, i)
@@ -31,7 +35,7 @@ func TestSimpleCache(t *testing.T) {
 	for it := cache.NewCacheIt(); it.HasCurr(); it.Next() {
 		entry := it.GetCurr()
 		assert.Equal(t, entry.state, BUSY)
-		assert.False(t, entry.hasExpired(time.Now()))
+		assert.False(t, entry.hasExpired(clock.Now()))
 	}
 
 	assert.Equal(t, cache.NumEntries(), Capacity)
@@ -52,12 +56,12 @@ func TestSimpleCache(t *testing.T) {
 
 	fmt.Printf("Wait for ttl = %s\n", ttl)
 
-	time.Sleep(ttl) // I need to test that TTL works
+	clock.Advance(ttl) // I need to test that TTL works
 
 	value, err = cache.Read(Capacity / 2)
 	assert.NotNil(t, err)
 
-	currTime := time.Now()
+	currTime := clock.Now()
 	for it := cache.NewCacheIt(); it.HasCurr(); it.Next() {
 		entry := it.GetCurr()
 		assert.True(t, entry.hasExpired(currTime))
@@ -81,21 +85,21 @@ func TestSimpleCache(t *testing.T) {
 	for it := cache.NewCacheIt(); it.HasCurr(); it.Next() {
 		entry := it.GetCurr()
 		assert.Equal(t, entry.state, BUSY)
-		assert.False(t, entry.hasExpired(time.Now()))
+		assert.False(t, entry.hasExpired(clock.Now()))
 	}
 
 	assert.Equal(t, cache.NumEntries(), Capacity)
 
 	elapsedTime := ttl
 	fmt.Printf("wait for %s\n", elapsedTime)
-	time.Sleep(elapsedTime)
+	clock.Advance(elapsedTime)
 
 	entry, err := cache.InsertOrUpdate(Capacity, Capacity)
 	assert.Nil(t, err)
 	assert.Equal(t, entry.(int), Capacity)
 
 	fmt.Printf("wait for %s\n", elapsedTime)
-	time.Sleep(elapsedTime) // after elapsing one more half ttl I should be able to insert a new entry
+	clock.Advance(elapsedTime) // after elapsing one more half ttl I should be able to insert a new entry
 
 	entry, err = cache.InsertOrUpdate(Capacity, Capacity)
 	assert.Nil(t, err)
@@ -107,6 +111,32 @@ func TestSimpleCache(t *testing.T) {
 	assert.Equal(t, mruValue.(int), Capacity)
 }
 
+// Manually advanced clock, so that the ttls can be tested without sleeping. Each reading moves it
+// one nanosecond forward, as the real time would do
+type fakeClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+func newFakeClock(cache *SimpleCache) *fakeClock {
+	clock := &fakeClock{now: time.Now()}
+	cache.SetClock(clock.Now)
+	return clock
+}
+
+func (clock *fakeClock) Now() time.Time {
+	clock.lock.Lock()
+	defer clock.lock.Unlock()
+	clock.now = clock.now.Add(time.Nanosecond)
+	return clock.now
+}
+
+func (clock *fakeClock) Advance(d time.Duration) {
+	clock.lock.Lock()
+	defer clock.lock.Unlock()
+	clock.now = clock.now.Add(d)
+}
+
 type ValueType struct {
 	Num  int
 	Text string
@@ -152,3 +182,1188 @@ func TestCompress(t *testing.T) {
 		assert.Equal(t, expStr, value.Text)
 	}
 }
+
+func newValueTypeCache(capacity int, ttl time.Duration) *SimpleCache {
+	return NewWithCompression(capacity, Factor, ttl,
+		func(key interface{}) (string, error) {
+			return strconv.Itoa(key.(int)), nil
+		}, func(value interface{}) ([]byte, error) {
+			content, ok := value.(*ValueType)
+			if !ok {
+				return nil, fmt.Errorf("unexpected value type %T", value)
+			}
+			return json.Marshal(content)
+		},
+		func(buf []byte) (interface{}, error) {
+			value := &ValueType{}
+			err := json.Unmarshal(buf, value)
+			if err != nil {
+				return nil, err
+			}
+			return value, nil
+		})
+}
+
+func TestSerializationErrorHandler(t *testing.T) {
+
+	cache := newValueTypeCache(Capacity, time.Hour)
+
+	// Without handler the error is propagated
+	_, err := cache.InsertOrUpdate(1, "not a *ValueType")
+	assert.Error(t, err)
+	assert.Equal(t, 1, cache.SerializationFailures())
+	assert.Equal(t, 0, cache.NumEntries())
+
+	var failedKeys []string
+	action := StoreRaw
+	cache.SetSerializationErrorHandler(func(key string, err error) FallbackAction {
+		failedKeys = append(failedKeys, key)
+		return action
+	})
+
+	_, err = cache.InsertOrUpdate(2, "raw value")
+	assert.NoError(t, err)
+	value, err := cache.Read(2)
+	assert.NoError(t, err)
+	assert.Equal(t, "raw value", value)
+
+	// A later valid value is compressed again
+	_, err = cache.InsertOrUpdate(2, &ValueType{Num: 2, Text: "compressed"})
+	assert.NoError(t, err)
+	value, err = cache.Read(2)
+	assert.NoError(t, err)
+	assert.Equal(t, "compressed", value.(*ValueType).Text)
+
+	action = Propagate
+	_, err = cache.InsertOrUpdate(3, 3)
+	assert.Error(t, err)
+
+	assert.Equal(t, []string{"2", "3"}, failedKeys)
+	assert.Equal(t, 3, cache.SerializationFailures())
+}
+
+func TestResurrectPolicy(t *testing.T) {
+
+	ttl := 100 * time.Millisecond
+	cache := New(Capacity, Factor, ttl, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	clock := newFakeClock(cache)
+
+	_, err := cache.InsertOrUpdate(1, 1)
+	assert.NoError(t, err)
+	_, err = cache.InsertOrUpdate(2, 2)
+	assert.NoError(t, err)
+
+	clock.Advance(ttl)
+
+	// Default policy resurrects the expired entry
+	_, err = cache.InsertOrUpdate(1, 10)
+	assert.NoError(t, err)
+	value, err := cache.Read(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, value)
+
+	cache.SetResurrectPolicy(Reject)
+	_, err = cache.InsertOrUpdate(2, 20)
+	assert.ErrorIs(t, err, ErrKeyExpired)
+	_, err = cache.Read(2)
+	assert.ErrorIs(t, err, ErrKeyExpired)
+
+	// Live entries and new keys are not affected
+	_, err = cache.InsertOrUpdate(1, 11)
+	assert.NoError(t, err)
+	_, err = cache.InsertOrUpdate(3, 3)
+	assert.NoError(t, err)
+}
+
+func TestClone(t *testing.T) {
+
+	cache := newValueTypeCache(Capacity, time.Hour)
+	for i := 0; i < 10; i++ {
+		_, err := cache.InsertOrUpdate(i, &ValueType{Num: i, Text: strconv.Itoa(i)})
+		assert.NoError(t, err)
+	}
+	_, err := cache.Read(3) // 3 becomes the mru
+	assert.NoError(t, err)
+
+	clone := cache.Clone()
+	assert.Equal(t, cache.NumEntries(), clone.NumEntries())
+	assert.Equal(t, cache.HitCount(), clone.HitCount())
+	assert.Equal(t, cache.MissCount(), clone.MissCount())
+
+	it, cloneIt := cache.NewCacheIt(), clone.NewCacheIt()
+	for it.HasCurr() && cloneIt.HasCurr() {
+		assert.Equal(t, it.GetCurr().key, cloneIt.GetCurr().key)
+		assert.Equal(t, it.GetCurr().expirationTime(), cloneIt.GetCurr().expirationTime())
+		it.Next()
+		cloneIt.Next()
+	}
+	assert.False(t, it.HasCurr())
+	assert.False(t, cloneIt.HasCurr())
+
+	// Mutations on the clone do not affect the original
+	_, err = clone.InsertOrUpdate(3, &ValueType{Num: 30, Text: "changed"})
+	assert.NoError(t, err)
+	_, err = clone.InsertOrUpdate(100, &ValueType{Num: 100})
+	assert.NoError(t, err)
+
+	value, err := cache.Read(3)
+	assert.NoError(t, err)
+	assert.Equal(t, "3", value.(*ValueType).Text)
+	_, err = cache.Read(100)
+	assert.Error(t, err)
+	assert.Equal(t, 10, cache.NumEntries())
+	assert.Equal(t, 11, clone.NumEntries())
+}
+
+func TestInsertSweepsExpiredWhenFull(t *testing.T) {
+
+	ttl := 100 * time.Millisecond
+	cache := New(3, Factor, ttl, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	clock := newFakeClock(cache)
+
+	for i := 0; i < 3; i++ {
+		_, err := cache.InsertOrUpdate(i, i)
+		assert.NoError(t, err)
+	}
+
+	clock.Advance(ttl)
+
+	// Updating 0 refreshes its ttl but keeps it as the lru entry
+	_, err := cache.InsertOrUpdate(0, 0)
+	assert.NoError(t, err)
+
+	_, err = cache.InsertOrUpdate(3, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, cache.NumEntries())
+
+	value, err := cache.Read(0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, value)
+	_, err = cache.Read(1)
+	assert.Error(t, err)
+
+	_, err = cache.InsertOrUpdate(4, 4)
+	assert.NoError(t, err)
+	_, err = cache.InsertOrUpdate(5, 5)
+	assert.ErrorIs(t, err, ErrCacheFull)
+}
+
+func TestReadCompressed(t *testing.T) {
+
+	cache := newValueTypeCache(Capacity, time.Hour)
+	_, err := cache.InsertOrUpdate(1, &ValueType{Num: 1, Text: "compressed"})
+	assert.NoError(t, err)
+
+	buf, err := cache.ReadCompressed(1)
+	assert.NoError(t, err)
+	value, err := cache.decodeValue(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "compressed", value.(*ValueType).Text)
+
+	// The returned bytes are a copy
+	buf[0] ^= 0xff
+	value, err = cache.Read(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "compressed", value.(*ValueType).Text)
+
+	_, err = cache.ReadCompressed(2)
+	assert.Error(t, err)
+
+	plain := New(Capacity, Factor, time.Hour, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	_, err = plain.InsertOrUpdate(1, 1)
+	assert.NoError(t, err)
+	_, err = plain.ReadCompressed(1)
+	assert.Error(t, err)
+}
+
+func TestNoopUpdates(t *testing.T) {
+
+	serializations := 0
+	cache := NewWithCompression(Capacity, Factor, time.Hour,
+		func(key interface{}) (string, error) {
+			return strconv.Itoa(key.(int)), nil
+		}, func(value interface{}) ([]byte, error) {
+			serializations++
+			return json.Marshal(value)
+		},
+		func(buf []byte) (interface{}, error) {
+			value := &ValueType{}
+			err := json.Unmarshal(buf, value)
+			return value, err
+		})
+	cache.SetValueEquals(func(a, b interface{}) bool {
+		return *a.(*ValueType) == *b.(*ValueType)
+	})
+
+	_, err := cache.InsertOrUpdate(1, &ValueType{Num: 1, Text: "one"})
+	assert.NoError(t, err)
+	expiration := cache.table["1"].expirationTime()
+
+	_, err = cache.InsertOrUpdate(1, &ValueType{Num: 1, Text: "one"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, serializations)
+	assert.Equal(t, 1, cache.NoopUpdates())
+	assert.Equal(t, expiration, cache.table["1"].expirationTime())
+
+	cache.SetRefreshOnNoopUpdate(true)
+	_, err = cache.InsertOrUpdate(1, &ValueType{Num: 1, Text: "one"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, cache.NoopUpdates())
+	assert.True(t, cache.table["1"].expirationTime().After(expiration))
+
+	_, err = cache.InsertOrUpdate(1, &ValueType{Num: 1, Text: "uno"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, serializations)
+	assert.Equal(t, 2, cache.NoopUpdates())
+
+	value, err := cache.Read(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "uno", value.(*ValueType).Text)
+}
+
+func TestRotate(t *testing.T) {
+
+	cache := New(Capacity, Factor, time.Hour, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	for i := 0; i < 10; i++ {
+		_, err := cache.InsertOrUpdate(i, i)
+		assert.NoError(t, err)
+	}
+	hits, misses := cache.HitCount(), cache.MissCount()
+
+	old := cache.Rotate()
+	assert.Equal(t, 0, cache.NumEntries())
+	assert.Equal(t, 0, cache.HitCount())
+	assert.Equal(t, 10, old.NumEntries())
+	assert.Equal(t, hits, old.HitCount())
+	assert.Equal(t, misses, old.MissCount())
+	assert.False(t, cache.NewCacheIt().HasCurr())
+
+	_, err := cache.Read(1)
+	assert.Error(t, err)
+	_, err = cache.InsertOrUpdate(100, 100)
+	assert.NoError(t, err)
+
+	expected := 9
+	for it := old.NewCacheIt(); it.HasCurr(); it.Next() {
+		assert.Equal(t, strconv.Itoa(expected), it.GetCurr().key)
+		expected--
+	}
+	assert.Equal(t, -1, expected)
+
+	for i := 0; i < 10; i++ {
+		value, err := old.Read(i)
+		assert.NoError(t, err)
+		assert.Equal(t, i, value)
+	}
+	_, err = old.Read(100)
+	assert.Error(t, err)
+}
+
+func TestInsertOrUpdateWithReloader(t *testing.T) {
+
+	ttl := 100 * time.Millisecond
+	cache := New(Capacity, Factor, ttl, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	clock := newFakeClock(cache)
+
+	var reloads int32
+	release := make(chan struct{})
+	_, err := cache.InsertOrUpdateWithReloader(1, 1, func() (interface{}, error) {
+		atomic.AddInt32(&reloads, 1)
+		<-release
+		return 10, nil
+	})
+	assert.NoError(t, err)
+	_, err = cache.InsertOrUpdateWithReloader(2, 2, func() (interface{}, error) {
+		return nil, errors.New("backend down")
+	})
+	assert.NoError(t, err)
+	_, err = cache.InsertOrUpdate(3, 3)
+	assert.NoError(t, err)
+
+	value, err := cache.Read(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+
+	clock.Advance(ttl)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := cache.Read(1)
+			assert.NoError(t, err)
+			assert.Equal(t, 10, value)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&reloads))
+
+	value, err = cache.Read(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, value)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&reloads))
+
+	_, err = cache.Read(2)
+	assert.EqualError(t, err, "backend down")
+	_, err = cache.Read(3)
+	assert.ErrorIs(t, err, ErrKeyExpired)
+}
+
+func TestReloaderWithRejectPolicy(t *testing.T) {
+
+	ttl := time.Minute
+	cache := New(Capacity, Factor, ttl, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	clock := newFakeClock(cache)
+	cache.SetResurrectPolicy(Reject)
+
+	var reloads int32
+	_, err := cache.InsertOrUpdateWithReloader(1, 1, func() (interface{}, error) {
+		return int(atomic.AddInt32(&reloads, 1)) * 10, nil
+	})
+	assert.NoError(t, err)
+
+	clock.Advance(ttl)
+	_, err = cache.InsertOrUpdate(1, 2)
+	assert.ErrorIs(t, err, ErrKeyExpired)
+
+	// the reloaded value is written despite the policy
+	for i := 0; i < 2; i++ {
+		value, err := cache.Read(1)
+		assert.NoError(t, err)
+		assert.Equal(t, 10, value)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&reloads))
+}
+
+func TestLiveCount(t *testing.T) {
+
+	ttl := 100 * time.Millisecond
+	cache := New(Capacity, Factor, ttl, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	clock := newFakeClock(cache)
+
+	for i := 0; i < 10; i++ {
+		_, err := cache.InsertOrUpdate(i, i)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 10, cache.LiveCount())
+
+	clock.Advance(ttl)
+	for i := 0; i < 4; i++ {
+		_, err := cache.InsertOrUpdate(i, i)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 4, cache.LiveCount())
+	assert.Equal(t, 10, cache.NumEntries())
+
+	assert.NoError(t, cache.Clean())
+	_, err := cache.InsertOrUpdate(20, 20)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, cache.LiveCount())
+	assert.Equal(t, 1, cache.NumEntries())
+}
+
+func TestSetInitialMapSize(t *testing.T) {
+
+	cache := New(Capacity, 3, time.Hour, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	assert.NoError(t, cache.SetInitialMapSize(10))
+	assert.Equal(t, 10, cache.tableSize())
+	assert.Equal(t, 4*Capacity, cache.ExtendedCapacity())
+
+	for i := 0; i < Capacity; i++ {
+		_, err := cache.InsertOrUpdate(i, i)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, Capacity, cache.NumEntries())
+	assert.Error(t, cache.SetInitialMapSize(20))
+
+	assert.NoError(t, cache.Clean())
+	assert.Error(t, cache.SetInitialMapSize(20)) // the entries left by Clean are still in the table
+
+	assert.NoError(t, cache.Rotate().Clean())
+	assert.NoError(t, cache.SetInitialMapSize(0))
+	assert.Equal(t, 4*Capacity, cache.tableSize())
+}
+
+func TestGroupBy(t *testing.T) {
+
+	cache := newValueTypeCache(Capacity, time.Hour)
+	for i := 0; i < 10; i++ {
+		_, err := cache.InsertOrUpdate(i, &ValueType{Num: i, Text: strconv.Itoa(i % 3)})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, cache.PinImmutable(9))
+
+	groups := cache.GroupBy(func(key string, value interface{}) string {
+		return "mod" + value.(*ValueType).Text
+	})
+	assert.Equal(t, map[string][]string{
+		"mod0": {"6", "3", "0", "9"},
+		"mod1": {"7", "4", "1"},
+		"mod2": {"8", "5", "2"},
+	}, groups)
+}
+
+func TestRange(t *testing.T) {
+
+	cache := newValueTypeCache(Capacity, time.Hour)
+	for i := 0; i < 5; i++ {
+		_, err := cache.InsertOrUpdate(i, &ValueType{Num: i})
+		assert.NoError(t, err)
+	}
+
+	var keys []string
+	cache.Range(func(key string, value interface{}) bool {
+		assert.Equal(t, key, strconv.Itoa(value.(*ValueType).Num))
+		keys = append(keys, key)
+		return true
+	})
+	assert.Equal(t, []string{"4", "3", "2", "1", "0"}, keys)
+
+	keys = nil
+	cache.Range(func(key string, value interface{}) bool {
+		keys = append(keys, key)
+		return len(keys) < 2
+	})
+	assert.Equal(t, []string{"4", "3"}, keys)
+
+	// Only the read lock is held, so a concurrent read of a live key is not blocked
+	cache.Range(func(key string, value interface{}) bool {
+		done := make(chan error)
+		go func() {
+			_, err := cache.Read(0)
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Error("Read blocked by Range")
+		}
+		return false
+	})
+}
+
+func TestEvictTo(t *testing.T) {
+
+	ttl := 100 * time.Millisecond
+	cache := New(10, Factor, ttl, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	clock := newFakeClock(cache)
+
+	for i := 0; i < 10; i++ {
+		_, err := cache.InsertOrUpdate(i, i)
+		assert.NoError(t, err)
+	}
+	clock.Advance(ttl)
+	// 5..9 are refreshed and become the mru entries; 0..4 stay expired at the lru end
+	for i := 5; i < 10; i++ {
+		_, err := cache.InsertOrUpdate(i, i)
+		assert.NoError(t, err)
+		_, err = cache.Read(i)
+		assert.NoError(t, err)
+	}
+	// 0 is refreshed but stays at the lru end, so it is evicted after the expired ones
+	_, err := cache.InsertOrUpdate(0, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, cache.PinImmutable(9))
+
+	assert.Equal(t, 0, cache.EvictTo(9))
+	assert.Equal(t, 6, cache.EvictTo(3))
+	assert.Equal(t, 3, cache.NumEntries())
+
+	var keys []string
+	for it := cache.NewCacheIt(); it.HasCurr(); it.Next() {
+		keys = append(keys, it.GetCurr().key)
+	}
+	assert.Equal(t, []string{"8", "7", "6"}, keys)
+
+	value, err := cache.Read(9)
+	assert.NoError(t, err)
+	assert.Equal(t, 9, value)
+
+	// The cache refills up to its capacity
+	for i := 20; i < 27; i++ {
+		_, err := cache.InsertOrUpdate(i, i)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 10, cache.NumEntries())
+}
+
+func cacheKeys(cache *SimpleCache) []string {
+	var keys []string
+	for it := cache.NewCacheIt(); it.HasCurr(); it.Next() {
+		keys = append(keys, it.GetCurr().key)
+	}
+	return keys
+}
+
+func TestDelete(t *testing.T) {
+
+	cache := New(Capacity, Factor, time.Hour, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	for i := 0; i < 5; i++ {
+		_, err := cache.InsertOrUpdate(i, i)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []string{"4", "3", "2", "1", "0"}, cacheKeys(cache))
+
+	deleted, err := cache.Delete(4) // mru
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+	assert.Equal(t, []string{"3", "2", "1", "0"}, cacheKeys(cache))
+
+	deleted, err = cache.Delete(0) // lru
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+	assert.Equal(t, []string{"3", "2", "1"}, cacheKeys(cache))
+
+	deleted, err = cache.Delete(2) // middle
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+	assert.Equal(t, []string{"3", "1"}, cacheKeys(cache))
+	assert.Equal(t, 2, cache.NumEntries())
+
+	deleted, err = cache.Delete(2)
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+
+	_, err = cache.Read(2)
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	for _, key := range []int{3, 1} {
+		deleted, err = cache.Delete(key)
+		assert.NoError(t, err)
+		assert.True(t, deleted)
+	}
+	assert.Equal(t, 0, cache.NumEntries())
+	assert.Equal(t, &cache.head, cache.head.next)
+	assert.Equal(t, &cache.head, cache.head.prev)
+	assert.Empty(t, cacheKeys(cache))
+	assert.NoError(t, cache.VerifyCounts())
+
+	_, err = cache.InsertOrUpdate(7, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"7"}, cacheKeys(cache))
+
+	_, err = New(Capacity, Factor, time.Hour, func(key interface{}) (string, error) {
+		return "", errors.New("bad key")
+	}).Delete(1)
+	assert.Error(t, err)
+}
+
+func TestInsertIfRoom(t *testing.T) {
+
+	ttl := 100 * time.Millisecond
+	cache := New(3, Factor, ttl, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	clock := newFakeClock(cache)
+
+	for i := 0; i < 3; i++ {
+		inserted, err := cache.InsertIfRoom(i, i)
+		assert.NoError(t, err)
+		assert.True(t, inserted)
+	}
+
+	inserted, err := cache.InsertIfRoom(3, 3)
+	assert.NoError(t, err)
+	assert.False(t, inserted)
+	_, err = cache.Read(3)
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	// Updates do not need room
+	inserted, err = cache.InsertIfRoom(1, 10)
+	assert.NoError(t, err)
+	assert.True(t, inserted)
+
+	clock.Advance(ttl)
+	_, err = cache.InsertOrUpdate(0, 0) // 0 stays live at the lru end
+	assert.NoError(t, err)
+
+	inserted, err = cache.InsertIfRoom(3, 3)
+	assert.NoError(t, err)
+	assert.True(t, inserted)
+	value, err := cache.Read(0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, value)
+	assert.Equal(t, 2, cache.NumEntries())
+}
+
+func TestInsertOrUpdateWithTTL(t *testing.T) {
+
+	ttl := 100 * time.Millisecond
+	cache := New(Capacity, Factor, ttl, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	clock := newFakeClock(cache)
+
+	_, err := cache.InsertOrUpdateWithTTL(1, 1, 0)
+	assert.Error(t, err)
+	_, err = cache.InsertOrUpdateWithTTL(1, 1, -time.Second)
+	assert.Error(t, err)
+	assert.Equal(t, 0, cache.NumEntries())
+
+	_, err = cache.InsertOrUpdateWithTTL(1, 1, time.Hour)
+	assert.NoError(t, err)
+	_, err = cache.InsertOrUpdateWithTTL(2, 2, ttl/4)
+	assert.NoError(t, err)
+	_, err = cache.InsertOrUpdate(3, 3)
+	assert.NoError(t, err)
+
+	clock.Advance(ttl / 2)
+	_, err = cache.Read(2)
+	assert.ErrorIs(t, err, ErrKeyExpired)
+	_, err = cache.Read(3)
+	assert.NoError(t, err)
+
+	clock.Advance(ttl)
+	_, err = cache.Read(3)
+	assert.ErrorIs(t, err, ErrKeyExpired)
+
+	// Read refreshes the entry with its own ttl
+	before := clock.Now()
+	value, err := cache.Read(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+	assert.True(t, cache.table["1"].expirationTime().After(before.Add(time.Hour-time.Second)))
+}
+
+func TestInspect(t *testing.T) {
+
+	ttl := 100 * time.Millisecond
+	cache := newValueTypeCache(Capacity, ttl)
+	clock := newFakeClock(cache)
+	for i := 0; i < 3; i++ {
+		_, err := cache.InsertOrUpdate(i, &ValueType{Num: i})
+		assert.NoError(t, err)
+	}
+	hits, misses := cache.HitCount(), cache.MissCount()
+	keys := cacheKeys(cache)
+
+	value, present, expired, err := cache.Inspect(0)
+	assert.NoError(t, err)
+	assert.True(t, present)
+	assert.False(t, expired)
+	assert.Equal(t, 0, value.(*ValueType).Num)
+
+	_, present, _, err = cache.Inspect(10)
+	assert.NoError(t, err)
+	assert.False(t, present)
+
+	clock.Advance(ttl)
+	value, present, expired, err = cache.Inspect(1)
+	assert.NoError(t, err)
+	assert.True(t, present)
+	assert.True(t, expired)
+	assert.Equal(t, 1, value.(*ValueType).Num)
+
+	assert.Equal(t, hits, cache.HitCount())
+	assert.Equal(t, misses, cache.MissCount())
+	assert.Equal(t, keys, cacheKeys(cache))
+
+	assert.NoError(t, cache.Clean())
+	value, present, _, err = cache.Inspect(2)
+	assert.NoError(t, err)
+	assert.True(t, present)
+	assert.Equal(t, 2, value.(*ValueType).Num)
+}
+
+func TestReadAfterClean(t *testing.T) {
+
+	cache := New(Capacity, Factor, time.Hour, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	for i := 0; i < 3; i++ {
+		_, err := cache.InsertOrUpdate(i, i)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, cache.Clean())
+
+	hits := cache.HitCount()
+	_, err := cache.Read(1)
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	assert.Equal(t, hits, cache.HitCount())
+	assert.Equal(t, 1, cache.MissCount())
+	assert.Equal(t, []string{"2", "1", "0"}, cacheKeys(cache)) // not promoted
+
+	_, err = cache.InsertOrUpdate(1, 10)
+	assert.NoError(t, err)
+	value, err := cache.Read(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, value)
+}
+
+func TestConcurrentReadsKeepLruOrder(t *testing.T) {
+
+	const capacity = 10
+	cache := New(capacity, Factor, time.Hour, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	for i := 0; i < capacity; i++ {
+		_, err := cache.InsertOrUpdate(i, i)
+		assert.Nil(t, err)
+	}
+
+	hits := cache.HitCount()
+
+	// the even keys are read concurrently, more times than the read buffer can hold
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 100; n++ {
+				for i := 0; i < capacity; i += 2 {
+					value, err := cache.Read(i)
+					assert.Nil(t, err)
+					assert.Equal(t, i, value.(int))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 4*100*capacity/2, cache.HitCount()-hits)
+
+	// the odd keys were not read, so they are the lru ones and the first to be evicted
+	keys := cacheKeys(cache)
+	for i, key := range keys[capacity/2:] {
+		assert.Equal(t, strconv.Itoa(capacity-1-2*i), key)
+	}
+	assert.Equal(t, capacity/2, cache.EvictTo(capacity/2))
+	for i := 0; i < capacity; i++ {
+		_, err := cache.Read(i)
+		assert.Equal(t, i%2 != 0, errors.Is(err, ErrKeyNotFound))
+	}
+}
+
+func TestPeek(t *testing.T) {
+
+	ttl := 100 * time.Millisecond
+	cache := newValueTypeCache(Capacity, ttl)
+	clock := newFakeClock(cache)
+	for i := 0; i < 3; i++ {
+		_, err := cache.InsertOrUpdate(i, &ValueType{Num: i})
+		assert.NoError(t, err)
+	}
+	hits, misses := cache.HitCount(), cache.MissCount()
+	keys := cacheKeys(cache)
+	expiration := cache.table["0"].expirationTime()
+
+	value, err := cache.Peek(0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, value.(*ValueType).Num)
+	assert.Equal(t, keys, cacheKeys(cache))
+	assert.Equal(t, expiration, cache.table["0"].expirationTime())
+
+	_, err = cache.Peek(10)
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	clock.Advance(ttl)
+	_, err = cache.Peek(1)
+	assert.ErrorIs(t, err, ErrKeyExpired)
+
+	assert.Equal(t, hits, cache.HitCount())
+	assert.Equal(t, misses, cache.MissCount())
+}
+
+func TestInsertCounts(t *testing.T) {
+
+	cache := New(Capacity, Factor, time.Hour, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+
+	for i := 0; i < Capacity; i++ {
+		_, err := cache.InsertOrUpdate(i, i)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 0, cache.HitCount())
+	assert.Equal(t, Capacity, cache.MissCount())
+
+	for i := 0; i < Capacity; i++ {
+		_, err := cache.InsertOrUpdate(i, i+1)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, Capacity, cache.HitCount())
+	assert.Equal(t, Capacity, cache.MissCount())
+}
+
+func TestIsEmpty(t *testing.T) {
+
+	ttl := 50 * time.Millisecond
+	cache := New(Capacity, Factor, ttl, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	clock := newFakeClock(cache)
+	assert.True(t, cache.IsEmpty())
+
+	_, err := cache.InsertOrUpdate(1, 1)
+	assert.NoError(t, err)
+	_, err = cache.InsertOrUpdateWithTTL(2, 2, time.Hour)
+	assert.NoError(t, err)
+	_, err = cache.InsertOrUpdate(3, 3)
+	assert.NoError(t, err)
+	assert.False(t, cache.IsEmpty())
+
+	clock.Advance(ttl)
+	assert.False(t, cache.IsEmpty()) // 2 is still live, though it is not the mru
+
+	_, err = cache.Delete(2)
+	assert.NoError(t, err)
+	assert.True(t, cache.IsEmpty())
+	assert.Equal(t, 2, cache.NumEntries())
+
+	assert.NoError(t, cache.Clean())
+	assert.True(t, cache.IsEmpty())
+}
+
+func TestEvictionAndExpiredCounts(t *testing.T) {
+
+	ttl := 100 * time.Millisecond
+	cache := New(4, Factor, ttl, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	clock := newFakeClock(cache)
+
+	for i := 0; i < 4; i++ {
+		_, err := cache.InsertOrUpdate(i, i)
+		assert.NoError(t, err)
+	}
+	clock.Advance(ttl)
+	_, err := cache.InsertOrUpdate(3, 3) // 3 is live again
+	assert.NoError(t, err)
+
+	// read twice, reclaimed afterwards: a single expiration
+	for n := 0; n < 2; n++ {
+		_, err = cache.Read(0)
+		assert.ErrorIs(t, err, ErrKeyExpired)
+	}
+	assert.Equal(t, 1, cache.ExpiredCount())
+	_, err = cache.InsertOrUpdate(4, 4) // 0 is reclaimed to make room
+	assert.NoError(t, err)
+	assert.Equal(t, 1, cache.ExpiredCount())
+
+	// 1 and 2 are reclaimed as expired, and 3 is evicted live
+	assert.Equal(t, 3, cache.EvictTo(1))
+	assert.Equal(t, 3, cache.ExpiredCount())
+	assert.Equal(t, 1, cache.EvictionCount())
+
+	var state CacheState
+	buf, err := cache.GetState()
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal([]byte(buf), &state))
+	assert.Equal(t, 1, state.EvictionCount)
+	assert.Equal(t, 3, state.ExpiredCount)
+
+	assert.NoError(t, cache.Clean())
+	assert.Equal(t, 0, cache.ExpiredCount())
+	assert.Equal(t, 0, cache.EvictionCount())
+}
+
+func TestResize(t *testing.T) {
+
+	ttl := 100 * time.Millisecond
+	cache := New(4, Factor, ttl, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	clock := newFakeClock(cache)
+	assert.Error(t, cache.Resize(0))
+
+	for i := 0; i < 4; i++ {
+		_, err := cache.InsertOrUpdate(i, i)
+		assert.NoError(t, err)
+	}
+	_, err := cache.InsertOrUpdate(4, 4)
+	assert.ErrorIs(t, err, ErrCacheFull)
+
+	// growth
+	assert.NoError(t, cache.Resize(8))
+	assert.Equal(t, 8, cache.Capacity())
+	assert.Equal(t, 10, cache.ExtendedCapacity())
+	for i := 4; i < 8; i++ {
+		_, err = cache.InsertOrUpdate(i, i)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 8, cache.NumEntries())
+
+	// shrink with eviction of the expired entries
+	clock.Advance(ttl)
+	for i := 4; i < 8; i++ {
+		_, err = cache.InsertOrUpdate(i, i) // 4..7 are live again
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, cache.Resize(5))
+	assert.Equal(t, 5, cache.Capacity())
+	assert.Equal(t, 4, cache.NumEntries())
+	for i := 4; i < 8; i++ {
+		value, err := cache.Read(i)
+		assert.NoError(t, err)
+		assert.Equal(t, i, value)
+	}
+
+	// shrink below the live entries
+	err = cache.Resize(2)
+	assert.ErrorIs(t, err, ErrCacheFull)
+	assert.Equal(t, 5, cache.Capacity())
+	assert.Equal(t, 4, cache.NumEntries())
+}
+
+func TestReverseCacheIt(t *testing.T) {
+
+	cache := New(Capacity, Factor, time.Hour, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+
+	it := cache.NewReverseCacheIt()
+	assert.False(t, it.HasCurr())
+	assert.Nil(t, it.Next())
+
+	for _, i := range []int{3, 1, 4, 5, 9} {
+		_, err := cache.InsertOrUpdate(i, i)
+		assert.NoError(t, err)
+	}
+	_, err := cache.Read(1)
+	assert.NoError(t, err)
+
+	forward := cacheKeys(cache)
+	var reverse []string
+	for it := cache.NewReverseCacheIt(); it.HasCurr(); it.Next() {
+		reverse = append(reverse, it.GetCurr().key)
+	}
+
+	assert.Equal(t, []string{"1", "9", "5", "4", "3"}, forward)
+	assert.Equal(t, []string{"3", "4", "5", "9", "1"}, reverse)
+}
+
+func TestGetStateWithoutLock(t *testing.T) {
+
+	cache := New(Capacity, Factor, time.Hour, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	for i := 0; i < 10; i++ {
+		_, err := cache.InsertOrUpdate(i, i)
+		assert.NoError(t, err)
+	}
+
+	// with the lock held, as by a goroutine in the middle of an operation
+	cache.writeLock()
+	buf, err := cache.GetState()
+	cache.lock.Unlock()
+	assert.NoError(t, err)
+
+	var state CacheState
+	assert.NoError(t, json.Unmarshal([]byte(buf), &state))
+	assert.Equal(t, 10, state.NumEntries)
+	assert.Equal(t, Capacity, state.Capacity)
+	assert.Equal(t, time.Hour, state.TTL)
+
+	// concurrently with writers; meant to be run with -race
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			_, _ = cache.InsertOrUpdate(i, i)
+			_ = cache.Resize(Capacity + i%2)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		_, err = cache.GetState()
+		assert.NoError(t, err)
+	}
+	wg.Wait()
+}
+
+func TestAbsoluteExpiration(t *testing.T) {
+
+	ttl := time.Minute
+	cache := New(Capacity, Factor, ttl, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	clock := newFakeClock(cache)
+	cache.SetExpirationPolicy(AbsoluteExpiration)
+
+	_, err := cache.InsertOrUpdate(1, 1)
+	assert.NoError(t, err)
+
+	// read repeatedly, it still expires ttl after the insertion
+	for elapsed := time.Duration(0); elapsed < ttl; elapsed += ttl / 4 {
+		_, err = cache.Read(1)
+		assert.NoError(t, err)
+		clock.Advance(ttl / 4)
+	}
+	_, err = cache.Read(1)
+	assert.ErrorIs(t, err, ErrKeyExpired)
+
+	// an update restarts the lifetime
+	_, err = cache.InsertOrUpdate(1, 2)
+	assert.NoError(t, err)
+	clock.Advance(ttl / 2)
+	value, err := cache.Read(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, value)
+	clock.Advance(ttl / 2)
+	_, err = cache.Read(1)
+	assert.ErrorIs(t, err, ErrKeyExpired)
+
+	// with the default policy, the same reads keep it alive
+	cache.SetExpirationPolicy(SlidingExpiration)
+	_, err = cache.InsertOrUpdate(1, 3)
+	assert.NoError(t, err)
+	for elapsed := time.Duration(0); elapsed < 2*ttl; elapsed += ttl / 4 {
+		_, err = cache.Read(1)
+		assert.NoError(t, err)
+		clock.Advance(ttl / 4)
+	}
+	_, err = cache.Read(1)
+	assert.NoError(t, err)
+}
+
+func TestContains(t *testing.T) {
+
+	ttl := time.Minute
+	decodes := 0
+	cache := NewWithCompression(Capacity, Factor, ttl,
+		func(key interface{}) (string, error) {
+			n, ok := key.(int)
+			if !ok {
+				return "", errors.New("not an int")
+			}
+			return strconv.Itoa(n), nil
+		}, func(value interface{}) ([]byte, error) {
+			return json.Marshal(value)
+		}, func(buf []byte) (interface{}, error) {
+			decodes++
+			var value int
+			err := json.Unmarshal(buf, &value)
+			return value, err
+		})
+	clock := newFakeClock(cache)
+
+	for i := 0; i < 3; i++ {
+		_, err := cache.InsertOrUpdate(i, i)
+		assert.NoError(t, err)
+	}
+	hits, misses := cache.HitCount(), cache.MissCount()
+	keys := cacheKeys(cache)
+
+	found, err := cache.Contains(0)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, keys, cacheKeys(cache))
+
+	found, err = cache.Contains(10)
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	_, err = cache.Contains("bad")
+	assert.Error(t, err)
+
+	clock.Advance(ttl)
+	found, err = cache.Contains(1)
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	assert.Equal(t, 0, decodes)
+	assert.Equal(t, hits, cache.HitCount())
+	assert.Equal(t, misses, cache.MissCount())
+}
+
+func TestInsertIntoFullCache(t *testing.T) {
+
+	const capacity = 8
+	ttl := time.Hour
+	cache := New(capacity, Factor, ttl, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	clock := newFakeClock(cache)
+
+	for i := 0; i < capacity; i++ {
+		_, err := cache.InsertOrUpdate(i, i)
+		assert.NoError(t, err)
+	}
+	keys := cacheKeys(cache)
+
+	_, err := cache.InsertOrUpdate(capacity, capacity)
+	assert.ErrorIs(t, err, ErrCacheFull)
+	assert.EqualError(t, err, "cache is full")
+
+	// nothing was changed by the failed insertion
+	assert.Equal(t, capacity, cache.NumEntries())
+	assert.Equal(t, keys, cacheKeys(cache))
+	assert.NoError(t, cache.VerifyCounts())
+	assert.Equal(t, 0, cache.EvictionCount())
+	found, err := cache.Contains(capacity)
+	assert.NoError(t, err)
+	assert.False(t, found)
+	for i := 0; i < capacity; i++ {
+		value, err := cache.Peek(i)
+		assert.NoError(t, err)
+		assert.Equal(t, i, value)
+	}
+
+	// an update of a present key does not need room
+	_, err = cache.InsertOrUpdate(0, 100)
+	assert.NoError(t, err)
+
+	// once the entries expire, the insertion reclaims one of them
+	clock.Advance(ttl)
+	_, err = cache.InsertOrUpdate(capacity, capacity)
+	assert.NoError(t, err)
+	assert.Equal(t, capacity, cache.NumEntries())
+	assert.NoError(t, cache.VerifyCounts())
+	value, err := cache.Read(capacity)
+	assert.NoError(t, err)
+	assert.Equal(t, capacity, value)
+}
+
+func BenchmarkReadParallel(b *testing.B) {
+
+	const numKeys = 1024
+	cache := New(numKeys, Factor, time.Hour, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	for i := 0; i < numKeys; i++ {
+		_, err := cache.InsertOrUpdate(i, i)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	var seed int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		// each goroutine reads its own slice of keys
+		i := int(atomic.AddInt64(&seed, 1)) * 64
+		for pb.Next() {
+			if _, err := cache.Read(i % numKeys); err != nil {
+				b.Fatal(err)
+			}
+			i++
+			if i%64 == 0 {
+				i -= 64
+			}
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package simple_cache
+
+import (
+	"errors"
+	"time"
+)
+
+// ReadSmart Resilient read combining stale-while-revalidate and read-through:
+//
+// - if key is live, its value is returned as Read does.
+//
+// - if key has expired less than grace ago, the stale value is returned with stale = true, and
+// loader is called in background to refresh the entry.
+//
+// - otherwise (expired beyond grace or not in the cache), loader is called synchronously and its
+// result is inserted and returned.
+//
+// Concurrent loads of the same key are coalesced into a single loader call. A load error is returned
+// to the synchronous callers; background load errors are discarded
+func (cache *SimpleCache) ReadSmart(key interface{}, grace time.Duration,
+	loader func() (interface{}, error)) (value interface{}, stale bool, err error) {
+
+	value, err = cache.Read(key)
+	if err == nil {
+		return value, false, nil
+	}
+	if !errors.Is(err, ErrKeyExpired) && !errors.Is(err, ErrKeyNotFound) {
+		return nil, false, err
+	}
+
+	stringKey, err := cache.toMapKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	load := func() (interface{}, error) {
+		return cache.reloads.do(stringKey, func() (interface{}, error) {
+			value, err := loader()
+			if err != nil {
+				return nil, err
+			}
+			if _, err = cache.insertOrUpdate(key, value, insertOptions{ttl: cache.ttl, refresh: true}); err != nil {
+				return nil, err
+			}
+			return value, nil
+		})
+	}
+
+	if stored, raw, ok := cache.staleValue(stringKey, grace); ok {
+		value = stored
+		if cache.toCompress && !raw {
+			value, err = cache.decodeValue(stored.([]byte))
+		}
+		if err == nil {
+			go func() { _, _ = load() }()
+			return value, true, nil
+		}
+		cache.serializationFailed(stringKey, err)
+	}
+
+	value, err = load()
+	if err != nil {
+		return nil, false, err
+	}
+
+	return value, false, nil
+}
+
+// Return the stored value of stringKey if it has expired less than grace ago
+func (cache *SimpleCache) staleValue(stringKey string, grace time.Duration) (interface{}, bool, bool) {
+
+	defer cache.lock.RUnlock()
+	cache.lock.RLock()
+
+	entry := cache.table[stringKey]
+	if entry == nil || entry.state != BUSY || entry.expirationTime().Add(grace).Before(cache.now()) {
+		return nil, false, false
+	}
+
+	return entry.value, entry.raw, true
+}
@@ -0,0 +1,60 @@
+package simple_cache
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadSmart(t *testing.T) {
+
+	ttl := 50 * time.Millisecond
+	cache := New(Capacity, Factor, ttl, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+	clock := newFakeClock(cache)
+
+	var loads int32
+	loader := func() (interface{}, error) {
+		return int(atomic.AddInt32(&loads, 1)) * 100, nil
+	}
+
+	// Not in the cache: synchronous load
+	value, stale, err := cache.ReadSmart(1, time.Hour, loader)
+	assert.NoError(t, err)
+	assert.False(t, stale)
+	assert.Equal(t, 100, value)
+
+	// Fresh
+	value, stale, err = cache.ReadSmart(1, time.Hour, loader)
+	assert.NoError(t, err)
+	assert.False(t, stale)
+	assert.Equal(t, 100, value)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+
+	// Expired within grace: stale value and background reload
+	clock.Advance(ttl)
+	value, stale, err = cache.ReadSmart(1, time.Hour, loader)
+	assert.NoError(t, err)
+	assert.True(t, stale)
+	assert.Equal(t, 100, value)
+	assert.Eventually(t, func() bool {
+		value, err := cache.Read(1)
+		return err == nil && value == 200
+	}, time.Second, 5*time.Millisecond)
+
+	// Expired beyond grace: synchronous load
+	clock.Advance(2 * ttl)
+	value, stale, err = cache.ReadSmart(1, ttl/2, loader)
+	assert.NoError(t, err)
+	assert.False(t, stale)
+	assert.Equal(t, 300, value)
+
+	_, _, err = cache.ReadSmart(2, time.Hour, func() (interface{}, error) {
+		return nil, errors.New("backend down")
+	})
+	assert.EqualError(t, err, "backend down")
+}
@@ -0,0 +1,210 @@
+package simple_cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// Disk tier where live entries are spilled when the cache is full
+type spillTier struct {
+	dir       string
+	maxBytes  int64
+	usedBytes int64
+	entries   map[string]*spilledEntry // indexed by stringficated key
+	hits      int
+	misses    int
+}
+
+type spilledEntry struct {
+	path           string
+	size           int64
+	timestamp      time.Time
+	expirationTime time.Time
+	ttl            time.Duration
+	order          int64
+	reload         func() (interface{}, error)
+}
+
+// EnableSpill Enable a disk tier for the cache. When the cache is full and its lru entry is still
+// live, instead of failing the insertion with ErrCacheFull, the lru entry is written to a file in
+// dir and evicted from memory. A later Read of a key that is not in memory looks for it in dir;
+// if found and not expired, the entry is loaded back into memory as the mru entry and the disk
+// copy is removed.
+//
+// maxDiskBytes bounds the total size of the spilled values; when it would be exceeded, the
+// insertion fails with ErrCacheFull as without the tier.
+//
+// Spilled values are the compressed bytes, so the tier is only available in compression mode.
+// Values stored raw after a serialization failure are never spilled. The disk accesses are done
+// with the internal lock taken.
+func (cache *SimpleCache) EnableSpill(dir string, maxDiskBytes int64) error {
+
+	if !cache.toCompress {
+		return errors.New("spill tier requires a cache created with NewWithCompression")
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	cache.writeLock()
+	defer cache.lock.Unlock()
+
+	if cache.spill != nil {
+		return errors.New("spill tier already enabled")
+	}
+
+	cache.spill = &spillTier{
+		dir:      dir,
+		maxBytes: maxDiskBytes,
+		entries:  make(map[string]*spilledEntry),
+	}
+
+	return nil
+}
+
+// SpillHits Return the number of reads served from the disk tier
+func (cache *SimpleCache) SpillHits() int {
+
+	cache.lock.RLock()
+	defer cache.lock.RUnlock()
+
+	if cache.spill == nil {
+		return 0
+	}
+	return cache.spill.hits
+}
+
+// SpillMisses Return the number of reads that missed in memory and in the disk tier
+func (cache *SimpleCache) SpillMisses() int {
+
+	cache.lock.RLock()
+	defer cache.lock.RUnlock()
+
+	if cache.spill == nil {
+		return 0
+	}
+	return cache.spill.misses
+}
+
+// Look for stringKey in the disk tier and move it back to memory; mutex must be taken.
+// Return the stored value and true if the key was found live on disk
+func (cache *SimpleCache) loadSpilled(stringKey string, currTime time.Time) (interface{}, bool, error) {
+
+	spilled := cache.spill.entries[stringKey]
+	if spilled == nil || spilled.expirationTime.Before(currTime) {
+		cache.spill.remove(stringKey)
+		cache.spill.misses++
+		return nil, false, nil
+	}
+
+	buf, err := os.ReadFile(spilled.path)
+	if err != nil {
+		cache.spill.remove(stringKey)
+		cache.spill.misses++
+		return nil, false, fmt.Errorf("cannot load spilled key %s: %w", stringKey, err)
+	}
+
+	cache.spill.hits++
+	atomic.AddInt64(&cache.hitCount, 1)
+
+	cost, err := cache.storedCost(stringKey, buf)
+	if err != nil || cache.reserveCost(cost, nil, currTime) != nil {
+		return buf, true, nil // the value is served but it stays on disk
+	}
+	entry, err := cache.allocateEntry(stringKey)
+	if err != nil {
+		return buf, true, nil
+	}
+	cache.spill.remove(stringKey)
+
+	entry.value = buf
+	entry.raw = false
+	cache.setCost(entry, cost)
+	entry.reload = spilled.reload // the entry could be the lru just spilled, with its own reloader
+	entry.version++
+	entry.timestamp = spilled.timestamp
+	entry.ttl = spilled.ttl
+	entry.setExpirationTime(spilled.expirationTime)
+	cache.touch(entry, currTime)
+	entry.order = spilled.order
+	if cache.orderKey != nil {
+		cache.insertIntoOrderIndex(entry)
+	}
+
+	return buf, true, nil
+}
+
+// Write the value of entry to disk. If the tier is full, the entries expired at currTime are deleted first
+func (spill *spillTier) store(entry *SimpleCacheEntry, currTime time.Time) error {
+
+	buf, ok := entry.value.([]byte)
+	if !ok || entry.raw {
+		return errors.New("raw values cannot be spilled")
+	}
+
+	spill.remove(entry.key)
+	size := int64(len(buf))
+	if spill.usedBytes+size > spill.maxBytes {
+		spill.removeExpired(currTime)
+	}
+	if spill.usedBytes+size > spill.maxBytes {
+		return errors.New("spill tier is full")
+	}
+
+	sum := sha256.Sum256([]byte(entry.key))
+	path := filepath.Join(spill.dir, hex.EncodeToString(sum[:]))
+	if err := os.WriteFile(path, buf, 0o600); err != nil {
+		return err
+	}
+
+	spill.usedBytes += size
+	spill.entries[entry.key] = &spilledEntry{
+		path:           path,
+		size:           size,
+		timestamp:      entry.timestamp,
+		expirationTime: entry.expirationTime(),
+		ttl:            entry.ttl,
+		order:          entry.order,
+		reload:         entry.reload,
+	}
+
+	return nil
+}
+
+// Delete the disk copy of stringKey, if any
+func (spill *spillTier) remove(stringKey string) {
+	spilled := spill.entries[stringKey]
+	if spilled == nil {
+		return
+	}
+	_ = os.Remove(spilled.path)
+	spill.usedBytes -= spilled.size
+	delete(spill.entries, stringKey)
+}
+
+// Delete the spilled entries that have expired at currTime. Return how many were deleted
+func (spill *spillTier) removeExpired(currTime time.Time) int {
+	removed := 0
+	for stringKey, spilled := range spill.entries {
+		if spilled.expirationTime.Before(currTime) {
+			spill.remove(stringKey)
+			removed++
+		}
+	}
+	return removed
+}
+
+func (spill *spillTier) clean() {
+	for stringKey := range spill.entries {
+		spill.remove(stringKey)
+	}
+	spill.hits = 0
+	spill.misses = 0
+}
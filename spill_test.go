@@ -0,0 +1,135 @@
+package simple_cache
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSpill(t *testing.T) {
+
+	plain := New(Capacity, Factor, time.Hour, nil)
+	assert.Error(t, plain.EnableSpill(t.TempDir(), 1<<20))
+
+	dir := t.TempDir()
+	cache := newValueTypeCache(3, time.Hour)
+	assert.NoError(t, cache.EnableSpill(dir, 1<<20))
+
+	for i := 0; i < 5; i++ {
+		_, err := cache.InsertOrUpdate(i, &ValueType{Num: i, Text: fmt.Sprintf("value %d", i)})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 3, cache.NumEntries())
+
+	files, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 2) // 0 and 1 were spilled
+
+	// Reading 0 brings it back to memory and spills the current lru (2)
+	value, err := cache.Read(0)
+	assert.NoError(t, err)
+	assert.Equal(t, "value 0", value.(*ValueType).Text)
+	assert.Equal(t, 1, cache.SpillHits())
+	key, _, err := cache.GetMRU()
+	assert.NoError(t, err)
+	assert.Equal(t, "0", key)
+
+	for i := 0; i < 5; i++ {
+		value, err := cache.Read(i)
+		assert.NoError(t, err)
+		assert.Equal(t, i, value.(*ValueType).Num)
+	}
+
+	_, err = cache.Read(10)
+	assert.Error(t, err)
+	assert.Equal(t, 1, cache.SpillMisses())
+
+	assert.NoError(t, cache.Clean())
+	files, err = os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestSpillFull(t *testing.T) {
+
+	cache := newValueTypeCache(2, time.Hour)
+	assert.NoError(t, cache.EnableSpill(t.TempDir(), 1))
+
+	for i := 0; i < 2; i++ {
+		_, err := cache.InsertOrUpdate(i, &ValueType{Num: i})
+		assert.NoError(t, err)
+	}
+	_, err := cache.InsertOrUpdate(2, &ValueType{Num: 2})
+	assert.ErrorIs(t, err, ErrCacheFull)
+}
+
+func TestSpillKeepsReloader(t *testing.T) {
+
+	ttl := time.Minute
+	cache := newValueTypeCache(2, ttl)
+	clock := newFakeClock(cache)
+	assert.NoError(t, cache.EnableSpill(t.TempDir(), 1<<20))
+
+	_, err := cache.InsertOrUpdateWithReloader(1, &ValueType{Num: 1}, func() (interface{}, error) {
+		return &ValueType{Num: 1, Text: "reloaded"}, nil
+	})
+	assert.NoError(t, err)
+	_, err = cache.InsertOrUpdateWithReloader(2, &ValueType{Num: 2}, func() (interface{}, error) {
+		return &ValueType{Num: 2, Text: "reloaded"}, nil
+	})
+	assert.NoError(t, err)
+	_, err = cache.InsertOrUpdate(3, &ValueType{Num: 3}) // spills 1
+	assert.NoError(t, err)
+	_, err = cache.Read(3)
+	assert.NoError(t, err)
+
+	// 1 comes back from disk, reusing the entry of 2, which is spilled in turn
+	value, err := cache.Read(1)
+	assert.NoError(t, err)
+	assert.Equal(t, &ValueType{Num: 1}, value)
+	assert.Equal(t, 1, cache.SpillHits())
+
+	clock.Advance(ttl)
+
+	value, err = cache.Read(1)
+	assert.NoError(t, err)
+	assert.Equal(t, &ValueType{Num: 1, Text: "reloaded"}, value)
+}
+
+func TestSpillRemovesExpired(t *testing.T) {
+
+	ttl := time.Minute
+	dir := t.TempDir()
+	cache := newValueTypeCache(1, ttl)
+	clock := newFakeClock(cache)
+
+	_, err := cache.InsertOrUpdate(0, &ValueType{Num: 0})
+	assert.NoError(t, err)
+	size := int64(len(cache.table["0"].value.([]byte)))
+	assert.NoError(t, cache.EnableSpill(dir, 2*size))
+
+	for i := 1; i < 3; i++ { // spills 0 and 1, filling the tier
+		_, err = cache.InsertOrUpdate(i, &ValueType{Num: i})
+		assert.NoError(t, err)
+	}
+	_, err = cache.InsertOrUpdate(3, &ValueType{Num: 3})
+	assert.ErrorIs(t, err, ErrCacheFull)
+
+	// once expired, the spilled entries make room for new ones
+	clock.Advance(ttl)
+	_, err = cache.InsertOrUpdate(2, &ValueType{Num: 2})
+	assert.NoError(t, err)
+	_, err = cache.InsertOrUpdate(3, &ValueType{Num: 3}) // spills 2
+	assert.NoError(t, err)
+	files, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	clock.Advance(ttl)
+	assert.Equal(t, 1, cache.PruneExpired())
+	files, err = os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+}
@@ -0,0 +1,68 @@
+package simple_cache
+
+import (
+	"fmt"
+	"time"
+)
+
+// TypedCache A type-safe wrapper around SimpleCache, so callers do not need type assertions
+type TypedCache[K comparable, V any] struct {
+	cache *SimpleCache
+}
+
+// NewTyped Create a TypedCache. Parameters are the same as New, but the key stringification receives K.
+// If toKey is nil, DefaultKeyFunc is used
+func NewTyped[K comparable, V any](capacity int, capFactor float64, ttl time.Duration,
+	toKey func(K) (string, error)) *TypedCache[K, V] {
+
+	var toMapKey func(key interface{}) (string, error) // nil means DefaultKeyFunc
+	if toKey != nil {
+		toMapKey = func(key interface{}) (string, error) {
+			return toKey(key.(K))
+		}
+	}
+
+	return &TypedCache[K, V]{cache: New(capacity, capFactor, ttl, toMapKey)}
+}
+
+// InsertOrUpdate Insert into the cache the pair key,value. See SimpleCache.InsertOrUpdate
+func (typed *TypedCache[K, V]) InsertOrUpdate(key K, value V) error {
+	_, err := typed.cache.InsertOrUpdate(key, value)
+	return err
+}
+
+// Read Retrieve the value associated to key. On error, the zero value of V is returned.
+// See SimpleCache.Read
+func (typed *TypedCache[K, V]) Read(key K) (V, error) {
+	value, err := typed.cache.Read(key)
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+	return typedValue[V](value)
+}
+
+// GetMRU Return the most recently used entry. On error, the zero value of V is returned.
+// See SimpleCache.GetMRU
+func (typed *TypedCache[K, V]) GetMRU() (string, V, error) {
+	key, value, err := typed.cache.GetMRU()
+	if err != nil {
+		var zero V
+		return key, zero, err
+	}
+	ret, err := typedValue[V](value)
+	return key, ret, err
+}
+
+// Convert a stored value back to V. A nil value stored for a nil-able V is its zero value
+func typedValue[V any](value interface{}) (V, error) {
+	if value == nil {
+		var zero V
+		return zero, nil
+	}
+	ret, ok := value.(V)
+	if !ok {
+		return ret, fmt.Errorf("unexpected value type %T", value)
+	}
+	return ret, nil
+}
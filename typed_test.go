@@ -0,0 +1,44 @@
+package simple_cache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTypedCache(t *testing.T) {
+
+	cache := NewTyped[int, *ValueType](Capacity, Factor, time.Hour, func(key int) (string, error) {
+		return strconv.Itoa(key), nil
+	})
+
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, cache.InsertOrUpdate(i, &ValueType{Num: i}))
+	}
+
+	value, err := cache.Read(3)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, value.Num)
+
+	key, value, err := cache.GetMRU()
+	assert.NoError(t, err)
+	assert.Equal(t, "3", key)
+	assert.Equal(t, 3, value.Num)
+
+	value, err = cache.Read(10)
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	assert.Nil(t, value)
+
+	assert.NoError(t, cache.InsertOrUpdate(11, nil))
+	value, err = cache.Read(11)
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+
+	empty := NewTyped[string, int](Capacity, Factor, time.Hour, func(key string) (string, error) {
+		return key, nil
+	})
+	_, number, err := empty.GetMRU()
+	assert.Error(t, err)
+	assert.Equal(t, 0, number)
+}
@@ -0,0 +1,59 @@
+package simple_cache
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// SetAutoCorrectCounts Set whether VerifyCounts fixes numEntries when it diverges from the list. Default is false
+func (cache *SimpleCache) SetAutoCorrectCounts(autoCorrect bool) {
+
+	cache.writeLock()
+	defer cache.lock.Unlock()
+
+	cache.autoCorrectCounts = autoCorrect
+}
+
+// CorruptionCount Return the number of times VerifyCounts has found divergent counts
+func (cache *SimpleCache) CorruptionCount() int {
+	return int(atomic.LoadInt64(&cache.corruptionCount))
+}
+
+// VerifyCounts Check that the internal counts agree with the lru list: numEntries must be equal to
+// the number of BUSY entries in the list, and the table must hold exactly as many keys as entries
+// are in the list (the entries left AVAILABLE by Clean remain in both until they are reused).
+//
+// On divergence it increments the corruption counter and returns an error describing it. If
+// auto-correction is enabled, numEntries is also set to the number of BUSY entries found. The
+// check walks the whole list with the internal lock taken.
+func (cache *SimpleCache) VerifyCounts() error {
+
+	cache.writeLock()
+	defer cache.unlock()
+
+	listCount, busyCount := 0, 0
+	for entry := cache.head.next; entry != &cache.head; entry = entry.next {
+		listCount++
+		if entry.state == BUSY {
+			busyCount++
+		}
+		if listCount > len(cache.table)+int(cache.capacity)+1 {
+			break // the list is broken and probably has a cycle
+		}
+	}
+
+	if cache.numEntries == int64(busyCount) && len(cache.table) == listCount {
+		return nil
+	}
+
+	atomic.AddInt64(&cache.corruptionCount, 1)
+	err := fmt.Errorf("divergent counts: numEntries = %d, busy entries in list = %d, table size = %d, list size = %d",
+		cache.numEntries, busyCount, len(cache.table), listCount)
+
+	if cache.autoCorrectCounts {
+		atomic.StoreInt64(&cache.numEntries, int64(busyCount))
+		cache.checkFillThresholds()
+	}
+
+	return err
+}
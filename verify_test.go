@@ -0,0 +1,47 @@
+package simple_cache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifyCounts(t *testing.T) {
+
+	cache := New(10, Factor, time.Hour, func(key interface{}) (string, error) {
+		return strconv.Itoa(key.(int)), nil
+	})
+
+	for i := 0; i < 10; i++ {
+		_, err := cache.InsertOrUpdate(i, i)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, cache.VerifyCounts())
+
+	// Entries left by Clean are reused without drifting the counts
+	assert.NoError(t, cache.Clean())
+	assert.NoError(t, cache.VerifyCounts())
+	for i := 5; i < 20; i++ {
+		_, err := cache.InsertOrUpdate(i, i)
+		if i < 15 {
+			assert.NoError(t, err)
+		} else {
+			assert.ErrorIs(t, err, ErrCacheFull)
+		}
+		assert.NoError(t, cache.VerifyCounts())
+	}
+	assert.Equal(t, 10, cache.NumEntries())
+	assert.Equal(t, 0, cache.CorruptionCount())
+
+	cache.numEntries = 7
+	assert.Error(t, cache.VerifyCounts())
+	assert.Equal(t, 1, cache.CorruptionCount())
+	assert.Equal(t, 7, cache.NumEntries())
+
+	cache.SetAutoCorrectCounts(true)
+	assert.Error(t, cache.VerifyCounts())
+	assert.Equal(t, 2, cache.CorruptionCount())
+	assert.Equal(t, 10, cache.NumEntries())
+	assert.NoError(t, cache.VerifyCounts())
+}